@@ -0,0 +1,183 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLimitExceeded is returned by an adaptive limiter that rejects a call
+// because its concurrency limit is reached.
+var ErrLimitExceeded = errors.New("adaptive limiter: limit exceeded")
+
+const (
+	defaultInitialLimit = 20
+	defaultMaxLimit     = 1000
+)
+
+type adaptiveConfig struct {
+	algorithm    LimitAlgorithm
+	initialLimit uint
+	minLimit     uint
+	maxLimit     uint
+	isFailure    func(error) bool
+	clock        Clock
+}
+
+// AdaptiveOption configures an AdaptiveLimiter.
+type AdaptiveOption interface {
+	applyAdaptive(*adaptiveConfig)
+}
+
+type adaptiveOptionFunc func(*adaptiveConfig)
+
+func (f adaptiveOptionFunc) applyAdaptive(c *adaptiveConfig) {
+	f(c)
+}
+
+// WithLimitAlgorithm sets the algorithm adjusting the limit. The default is
+// NewVegasLimit.
+func WithLimitAlgorithm(alg LimitAlgorithm) AdaptiveOption {
+	return adaptiveOptionFunc(func(c *adaptiveConfig) {
+		c.algorithm = alg
+	})
+}
+
+// WithInitialLimit sets the limit the limiter starts with. The default is 20.
+func WithInitialLimit(n uint) AdaptiveOption {
+	return adaptiveOptionFunc(func(c *adaptiveConfig) {
+		c.initialLimit = n
+	})
+}
+
+// WithLimitBounds keeps the limit between min and max. The defaults are 1
+// and 1000.
+func WithLimitBounds(min uint, max uint) AdaptiveOption {
+	return adaptiveOptionFunc(func(c *adaptiveConfig) {
+		c.minLimit = min
+		c.maxLimit = max
+	})
+}
+
+// AdaptiveLimiter wraps a circuit and caps how many calls to it may be in
+// flight at the same time, like a Bulkhead, but adjusts the cap as it goes.
+// After every call it hands the latency and the outcome of the call to its
+// LimitAlgorithm, which raises the limit while the dependency keeps up and
+// lowers it as the dependency slows down or fails. Errors that are not
+// failures, such as a canceled context, leave the limit alone.
+type AdaptiveLimiter[T any] struct {
+	circuit Circuit[T]
+	cfg     adaptiveConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+}
+
+// NewAdaptiveLimiter returns an adaptive limiter wrapping circuit.
+func NewAdaptiveLimiter[T any](circuit Circuit[T], opts ...AdaptiveOption) *AdaptiveLimiter[T] {
+	cfg := adaptiveConfig{
+		initialLimit: defaultInitialLimit,
+		minLimit:     1,
+		maxLimit:     defaultMaxLimit,
+	}
+	for _, opt := range opts {
+		opt.applyAdaptive(&cfg)
+	}
+	if cfg.algorithm == nil {
+		cfg.algorithm = NewVegasLimit()
+	}
+	if cfg.minLimit == 0 {
+		cfg.minLimit = 1
+	}
+	if cfg.maxLimit < cfg.minLimit {
+		cfg.maxLimit = cfg.minLimit
+	}
+	if cfg.isFailure == nil {
+		cfg.isFailure = IsFailure
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+
+	l := &AdaptiveLimiter[T]{
+		circuit: circuit,
+		cfg:     cfg,
+	}
+	l.limit = l.bound(float64(cfg.initialLimit))
+	return l
+}
+
+// Execute calls the wrapped circuit if fewer calls than the limit are in
+// flight, and returns ErrLimitExceeded otherwise.
+func (l *AdaptiveLimiter[T]) Execute(ctx context.Context) (*T, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	l.mu.Lock()
+	if l.inFlight >= int(l.limit) {
+		l.mu.Unlock()
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	start := l.cfg.clock.Now()
+	var err error
+	defer func() {
+		// A circuit that panics frees its slot and counts as a failure.
+		e := recover()
+		l.finish(inFlight, l.cfg.clock.Now().Sub(start), err, e != nil)
+		if e != nil {
+			panic(e)
+		}
+	}()
+
+	res, err := l.circuit(ctx)
+	return res, err
+}
+
+// finish frees the slot of a call and hands its outcome to the algorithm.
+func (l *AdaptiveLimiter[T]) finish(inFlight int, rtt time.Duration, err error, panicked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	failure := panicked || l.cfg.isFailure(err)
+	if err != nil && !failure {
+		return
+	}
+	l.limit = l.bound(l.cfg.algorithm.Update(l.limit, LimitSample{
+		RTT:      rtt,
+		InFlight: inFlight,
+		Dropped:  failure,
+	}))
+}
+
+// Circuit returns the limiter as a circuit function.
+func (l *AdaptiveLimiter[T]) Circuit() Circuit[T] {
+	return l.Execute
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter[T]) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// InFlight returns the number of calls currently running.
+func (l *AdaptiveLimiter[T]) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+func (l *AdaptiveLimiter[T]) bound(limit float64) float64 {
+	return max(float64(l.cfg.minLimit), min(float64(l.cfg.maxLimit), limit))
+}
@@ -0,0 +1,147 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestAdaptiveLimiterRejects(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	l := dist.NewAdaptiveLimiter(func(context.Context) (*string, error) {
+		started <- struct{}{}
+		<-release
+		res := "ok"
+		return &res, nil
+	}, dist.WithInitialLimit(2), dist.WithLimitAlgorithm(dist.NewAIMDLimit(0.5)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = l.Execute(context.Background())
+		}()
+	}
+	<-started
+	<-started
+
+	if l.InFlight() != 2 {
+		t.Fatalf("expected 2 calls in flight, got %d", l.InFlight())
+	}
+	if _, err := l.Execute(context.Background()); !errors.Is(err, dist.ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if l.Limit() < 3 {
+		t.Fatalf("expected the limit to grow, got %d", l.Limit())
+	}
+	if l.InFlight() != 0 {
+		t.Fatalf("expected no call in flight, got %d", l.InFlight())
+	}
+}
+
+func TestAdaptiveLimiterBacksOff(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+
+	l := dist.NewAdaptiveLimiter(failing.call,
+		dist.WithClock(clock),
+		dist.WithInitialLimit(16),
+		dist.WithLimitBounds(2, 100),
+		dist.WithLimitAlgorithm(dist.NewAIMDLimit(0.5)),
+	)
+
+	for i := 0; i < 10; i++ {
+		_, _ = l.Execute(context.Background())
+	}
+	if l.Limit() != 2 {
+		t.Fatalf("expected the limit to drop to its lower bound, got %d", l.Limit())
+	}
+
+	ignored := dist.NewAdaptiveLimiter(failing.call,
+		dist.WithInitialLimit(16),
+		dist.WithIsFailure(func(error) bool { return false }),
+	)
+	_, _ = ignored.Execute(context.Background())
+	if ignored.Limit() != 16 {
+		t.Fatalf("expected errors that are not failures to leave the limit alone, got %d", ignored.Limit())
+	}
+}
+
+func TestAdaptiveLimiterMeasuresLatency(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	latency := time.Millisecond * 10
+
+	var rtts []time.Duration
+	alg := dist.LimitAlgorithmFunc(func(limit float64, s dist.LimitSample) float64 {
+		rtts = append(rtts, s.RTT)
+		return limit
+	})
+	l := dist.NewAdaptiveLimiter(func(context.Context) (*string, error) {
+		clock.Advance(latency)
+		return nil, nil
+	}, dist.WithClock(clock), dist.WithLimitAlgorithm(alg))
+
+	_, _ = l.Execute(context.Background())
+	latency = time.Millisecond * 30
+	_, _ = l.Execute(context.Background())
+
+	if len(rtts) != 2 || rtts[0] != time.Millisecond*10 || rtts[1] != time.Millisecond*30 {
+		t.Fatalf("unexpected latencies %v", rtts)
+	}
+}
+
+func TestAdaptiveLimiterPanic(t *testing.T) {
+	l := dist.NewAdaptiveLimiter(func(context.Context) (*string, error) {
+		panic("boom")
+	}, dist.WithInitialLimit(4), dist.WithLimitAlgorithm(dist.NewAIMDLimit(0.5)))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the panic to reach the caller")
+			}
+		}()
+		_, _ = l.Execute(context.Background())
+	}()
+
+	if l.InFlight() != 0 {
+		t.Fatalf("expected the panicking call to free its slot, got %d in flight", l.InFlight())
+	}
+	if l.Limit() != 2 {
+		t.Fatalf("expected a panic to count as a failure, got limit %d", l.Limit())
+	}
+}
+
+func TestAdaptiveLimiterFailuresOnFakeClock(t *testing.T) {
+	// calls take no time on a clock that stands still, and must still lower
+	// the limit when they fail
+	algorithms := map[string]dist.LimitAlgorithm{
+		"vegas":     dist.NewVegasLimit(),
+		"gradient2": dist.NewGradient2Limit(0),
+	}
+	for name, alg := range algorithms {
+		failing := &flakyCircuit{}
+		failing.fail.Store(true)
+		l := dist.NewAdaptiveLimiter(failing.call,
+			dist.WithClock(dist.NewFakeClock(time.Now())),
+			dist.WithInitialLimit(20),
+			dist.WithLimitAlgorithm(alg),
+		)
+		for i := 0; i < 5; i++ {
+			_, _ = l.Execute(context.Background())
+		}
+		if l.Limit() >= 20 {
+			t.Fatalf("%s: expected failures to lower the limit, got %d", name, l.Limit())
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package dist
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const maxDuration = time.Duration(math.MaxInt64)
+
+// Backoff computes how long to wait before the next attempt at something that
+// keeps failing. Attempts are counted from 1; prev is the delay the backoff
+// returned for the previous attempt, or zero for the first one. Backoff
+// implementations in this package keep no state of their own, so a single
+// value can be shared by any number of breakers and retries.
+type Backoff interface {
+	Next(attempt uint, prev time.Duration) time.Duration
+}
+
+// BackoffFunc adapts an ordinary function to the Backoff interface.
+type BackoffFunc func(attempt uint, prev time.Duration) time.Duration
+
+func (f BackoffFunc) Next(attempt uint, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+// BackoffOption sets the backoff used by a primitive. It is accepted by every
+// constructor in this package that waits between attempts.
+type BackoffOption struct {
+	backoff Backoff
+}
+
+// WithBackoff sets the backoff deciding how long to wait. For a circuit
+// breaker it decides how long the circuit stays open, and the attempt passed
+// to it is the number of consecutive trips, so a probe that fails leads to
+// the next, usually longer, timeout. For a retry it decides how long to wait
+// before every re-attempt.
+func WithBackoff(b Backoff) BackoffOption {
+	return BackoffOption{backoff: b}
+}
+
+func (o BackoffOption) applyBreaker(c *breakerConfig) {
+	c.backoff = o.backoff
+}
+
+func (o BackoffOption) applyRetry(c *retryConfig) {
+	c.backoff = o.backoff
+}
+
+// NewConstantBackoff returns a backoff that always waits for d.
+func NewConstantBackoff(d time.Duration) Backoff {
+	return BackoffFunc(func(uint, time.Duration) time.Duration {
+		return d
+	})
+}
+
+// NewLinearBackoff returns a backoff that waits for base times the attempt
+// number, capped at max. A max of zero or less means no cap.
+func NewLinearBackoff(base time.Duration, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt uint, _ time.Duration) time.Duration {
+		return capBackoff(linear(base, attempt), max)
+	})
+}
+
+// NewExponentialBackoff returns a backoff that waits for base on the first
+// attempt and doubles the wait on every further one, capped at max. A max of
+// zero or less means no cap.
+func NewExponentialBackoff(base time.Duration, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt uint, _ time.Duration) time.Duration {
+		return capBackoff(exponential(base, attempt), max)
+	})
+}
+
+// NewFullJitterBackoff returns a backoff that waits for a random duration
+// between zero and the capped exponential backoff. Spreading the waits over
+// the whole range keeps replicas that failed at the same moment from retrying
+// at the same moment too.
+func NewFullJitterBackoff(base time.Duration, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt uint, _ time.Duration) time.Duration {
+		return between(0, capBackoff(exponential(base, attempt), max))
+	})
+}
+
+// NewDecorrelatedJitterBackoff returns a backoff that waits for a random
+// duration between base and three times the previous wait, capped at max.
+func NewDecorrelatedJitterBackoff(base time.Duration, max time.Duration) Backoff {
+	return BackoffFunc(func(_ uint, prev time.Duration) time.Duration {
+		upper := maxDuration
+		if prev <= maxDuration/3 {
+			upper = prev * 3
+		}
+		return capBackoff(between(base, upper), max)
+	})
+}
+
+func linear(base time.Duration, attempt uint) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	if attempt == 0 {
+		attempt = 1
+	}
+	if uint64(attempt) > uint64(maxDuration/base) {
+		return maxDuration
+	}
+	return base * time.Duration(attempt)
+}
+
+// exponential returns base * 2^(attempt-1), saturating instead of overflowing.
+func exponential(base time.Duration, attempt uint) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	if attempt == 0 {
+		attempt = 1
+	}
+	shift := attempt - 1
+	if shift >= 63 || base > maxDuration>>shift {
+		return maxDuration
+	}
+	return base << shift
+}
+
+func capBackoff(d time.Duration, max time.Duration) time.Duration {
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// between returns a random duration in [lower, upper).
+func between(lower time.Duration, upper time.Duration) time.Duration {
+	if upper <= lower {
+		return lower
+	}
+	return lower + time.Duration(rand.Int64N(int64(upper-lower)))
+}
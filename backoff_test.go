@@ -0,0 +1,74 @@
+package dist_test
+
+import (
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	b := dist.NewConstantBackoff(time.Second)
+	for attempt := uint(1); attempt < 10; attempt++ {
+		if d := b.Next(attempt, 0); d != time.Second {
+			t.Errorf("attempt %d: expected 1s, got %s", attempt, d)
+		}
+	}
+}
+
+func TestLinearBackoff(t *testing.T) {
+	b := dist.NewLinearBackoff(time.Second, time.Second*5)
+	expected := []time.Duration{time.Second, time.Second * 2, time.Second * 3, time.Second * 4, time.Second * 5, time.Second * 5}
+	for i, want := range expected {
+		if d := b.Next(uint(i+1), 0); d != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, d)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := dist.NewExponentialBackoff(time.Second, time.Minute)
+	expected := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 8, time.Second * 16, time.Second * 32, time.Minute}
+	for i, want := range expected {
+		if d := b.Next(uint(i+1), 0); d != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, d)
+		}
+	}
+}
+
+func TestExponentialBackoffDoesNotOverflow(t *testing.T) {
+	b := dist.NewExponentialBackoff(time.Second*2, 0)
+	prev := time.Duration(0)
+	for attempt := uint(1); attempt < 200; attempt++ {
+		d := b.Next(attempt, prev)
+		if d < prev {
+			t.Fatalf("attempt %d: backoff decreased from %s to %s", attempt, prev, d)
+		}
+		prev = d
+	}
+}
+
+func TestFullJitterBackoff(t *testing.T) {
+	b := dist.NewFullJitterBackoff(time.Second, time.Second*10)
+	for attempt := uint(1); attempt < 100; attempt++ {
+		d := b.Next(attempt, 0)
+		if d < 0 || d > time.Second*10 {
+			t.Fatalf("attempt %d: %s out of range", attempt, d)
+		}
+	}
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	b := dist.NewDecorrelatedJitterBackoff(time.Second, time.Second*30)
+	prev := time.Duration(0)
+	for attempt := uint(1); attempt < 100; attempt++ {
+		d := b.Next(attempt, prev)
+		if d < time.Second || d > time.Second*30 {
+			t.Fatalf("attempt %d: %s out of range", attempt, d)
+		}
+		if prev > time.Second && d > prev*3 {
+			t.Fatalf("attempt %d: %s exceeds three times %s", attempt, d, prev)
+		}
+		prev = d
+	}
+}
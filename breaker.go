@@ -3,42 +3,521 @@ package dist
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
 
-// Breaker function represents a circuit breaker. It wraps a circuit function
-// and returns a new circuit function that will return an error if the circuit
-// function fails more than failureThreshold times in a row.
-func Breaker[T any](circuit Circuit[T], failureThreshold uint) Circuit[T] {
-	var consecutiveFailures int
-	var lastAttempt = time.Now()
-	var m sync.RWMutex
+// ErrCircuitOpen is returned by a circuit breaker that rejects a call because
+// the circuit is open, or because it is half-open and all probe slots are
+// taken.
+var ErrCircuitOpen = errors.New("breaker: circuit open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// StateClosed lets all calls through and counts their failures.
+	StateClosed BreakerState = iota
+	// StateHalfOpen lets a limited number of probe calls through to decide
+	// whether the circuit can be closed again.
+	StateHalfOpen
+	// StateOpen rejects all calls until the open timeout expires.
+	StateOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("unknown state %d", int(s))
+	}
+}
+
+// BreakerCounts holds the numbers of calls and their outcomes seen by a
+// circuit breaker since it last changed state. The window fields are only
+// filled in when the breaker keeps a rolling window, that is in failure-rate
+// mode or with slow-call detection.
+type BreakerCounts struct {
+	Requests             uint32
+	TotalSuccesses       uint32
+	TotalFailures        uint32
+	TotalSlowCalls       uint32
+	ConsecutiveSuccesses uint32
+	ConsecutiveFailures  uint32
+	WindowCalls          uint32
+	WindowFailures       uint32
+	WindowSlowCalls      uint32
+}
+
+// FailureRate returns the percentage of failed calls in the rolling window.
+func (c BreakerCounts) FailureRate() float64 {
+	if c.WindowCalls == 0 {
+		return 0
+	}
+	return float64(c.WindowFailures) * 100 / float64(c.WindowCalls)
+}
+
+// SlowCallRate returns the percentage of slow calls in the rolling window.
+func (c BreakerCounts) SlowCallRate() float64 {
+	if c.WindowCalls == 0 {
+		return 0
+	}
+	return float64(c.WindowSlowCalls) * 100 / float64(c.WindowCalls)
+}
+
+func (c *BreakerCounts) onRequest() {
+	c.Requests++
+}
+
+func (c *BreakerCounts) onSuccess() {
+	c.TotalSuccesses++
+	c.ConsecutiveSuccesses++
+	c.ConsecutiveFailures = 0
+}
+
+func (c *BreakerCounts) onFailure() {
+	c.TotalFailures++
+	c.ConsecutiveFailures++
+	c.ConsecutiveSuccesses = 0
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = time.Second * 2
+	defaultMaxOpenTimeout   = time.Minute * 5
+	defaultMinimumCalls     = 10
+	defaultWindowSize       = 100
+)
+
+type breakerConfig struct {
+	failureThreshold uint
+	halfOpenProbes   uint
+	successThreshold uint
+	backoff          Backoff
+	failureRate      float64
+	minimumCalls     uint
+	windowSize       uint
+	windowSpan       time.Duration
+	windowBuckets    uint
+	slowCallDuration time.Duration
+	slowCallRate     float64
+	isFailure        func(error) bool
+	name             string
+	onStateChange    StateChangeFunc
+	store            BreakerStore
+	storeTimeout     time.Duration
+	clock            Clock
+}
+
+// BreakerOption configures a CircuitBreaker.
+type BreakerOption interface {
+	applyBreaker(*breakerConfig)
+}
+
+type breakerOptionFunc func(*breakerConfig)
+
+func (f breakerOptionFunc) applyBreaker(c *breakerConfig) {
+	f(c)
+}
+
+// WithFailureThreshold sets the number of consecutive failures that trip a
+// closed circuit. The default is 5.
+func WithFailureThreshold(n uint) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.failureThreshold = n
+	})
+}
+
+// WithHalfOpenProbes sets how many trial calls are let through while the
+// circuit is half-open. The default is 1. It is never lower than the success
+// threshold.
+func WithHalfOpenProbes(n uint) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.halfOpenProbes = n
+	})
+}
 
-	return func(ctx context.Context) (*T, error) {
-		m.RLock()
-		d := consecutiveFailures - int(failureThreshold)
-		if d >= 0 {
-			shouldRetryAt := lastAttempt.Add(time.Second * 2 << d)
-			if !time.Now().After(shouldRetryAt) {
-				m.RUnlock()
-				return nil, errors.New("service unreachable")
-			}
+// WithSuccessThreshold sets how many consecutive probe calls have to succeed
+// while half-open before the circuit is closed again. The default is 1.
+func WithSuccessThreshold(n uint) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.successThreshold = n
+	})
+}
+
+// WithOpenTimeout sets how long the circuit stays open after it trips for the
+// first time. Every consecutive trip doubles the timeout, up to 5m or d if it
+// is longer. The default is 2s. Use WithBackoff for another cap.
+func WithOpenTimeout(d time.Duration) BreakerOption {
+	return WithBackoff(NewExponentialBackoff(d, max(d, defaultMaxOpenTimeout)))
+}
+
+// WithFailureRate switches the breaker to failure-rate mode: instead of
+// counting consecutive failures, a closed circuit trips once the percentage
+// of failed calls in the rolling window reaches percent. The rate is only
+// evaluated when the window holds at least the minimum number of calls.
+func WithFailureRate(percent float64) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.failureRate = percent
+	})
+}
+
+// WithMinimumCalls sets how many calls the rolling window has to hold before
+// its failure rate is evaluated. The default is 10.
+func WithMinimumCalls(n uint) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.minimumCalls = n
+	})
+}
+
+// WithCountWindow makes the rolling window keep the outcomes of the last size
+// calls. This is the default, with a size of 100.
+func WithCountWindow(size uint) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.windowSize = size
+		c.windowSpan = 0
+	})
+}
+
+// WithTimeWindow makes the rolling window keep the outcomes of the calls made
+// during the last span, aggregated into the given number of buckets.
+func WithTimeWindow(span time.Duration, buckets uint) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.windowSpan = span
+		c.windowBuckets = buckets
+	})
+}
+
+// WithSlowCallThreshold makes the breaker classify calls that take longer than
+// d as slow, whether they fail or not. A closed circuit trips once the
+// percentage of slow calls in the rolling window reaches percent, and a slow
+// probe counts as a failed one while the circuit is half-open.
+func WithSlowCallThreshold(d time.Duration, percent float64) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.slowCallDuration = d
+		c.slowCallRate = percent
+	})
+}
+
+// CircuitBreaker wraps a circuit and stops calling it after it fails too many
+// times in a row. Once open, the breaker rejects calls with ErrCircuitOpen
+// until the open timeout expires, then turns half-open and lets a limited
+// number of probe calls through. Enough successful probes close the circuit,
+// a single failed probe opens it again with a longer timeout.
+//
+// In failure-rate mode the closed circuit trips on the share of failed calls
+// in a rolling window rather than on consecutive failures, which catches
+// dependencies that fail intermittently. With slow-call detection the same
+// window also tracks calls that take too long, so a dependency that answers
+// slowly trips the circuit just like one that errors.
+type CircuitBreaker[T any] struct {
+	circuit Circuit[T]
+	cfg     breakerConfig
+	window  *rollingWindow
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64
+	counts     BreakerCounts
+	trips      uint
+	openFor    time.Duration
+	openUntil  time.Time
+	forced     bool
+	pending    []BreakerEvent
+
+	subMu sync.Mutex
+	subs  map[chan BreakerEvent]struct{}
+}
+
+// NewCircuitBreaker returns a closed circuit breaker wrapping the given
+// circuit.
+func NewCircuitBreaker[T any](circuit Circuit[T], opts ...BreakerOption) *CircuitBreaker[T] {
+	cfg := breakerConfig{
+		failureThreshold: defaultFailureThreshold,
+		halfOpenProbes:   1,
+		successThreshold: 1,
+		backoff:          NewExponentialBackoff(defaultOpenTimeout, defaultMaxOpenTimeout),
+		minimumCalls:     defaultMinimumCalls,
+		windowSize:       defaultWindowSize,
+		isFailure:        IsFailure,
+		clock:            RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyBreaker(&cfg)
+	}
+	if cfg.failureThreshold == 0 {
+		cfg.failureThreshold = 1
+	}
+	if cfg.successThreshold == 0 {
+		cfg.successThreshold = 1
+	}
+	if cfg.halfOpenProbes < cfg.successThreshold {
+		cfg.halfOpenProbes = cfg.successThreshold
+	}
+	if cfg.backoff == nil {
+		cfg.backoff = NewExponentialBackoff(defaultOpenTimeout, defaultMaxOpenTimeout)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+	if cfg.isFailure == nil {
+		cfg.isFailure = IsFailure
+	}
+	if cfg.store != nil && cfg.name == "" {
+		panic("dist: WithStore requires WithName")
+	}
+	if cfg.storeTimeout <= 0 {
+		cfg.storeTimeout = defaultStoreTimeout
+	}
+
+	cb := &CircuitBreaker[T]{
+		circuit: circuit,
+		cfg:     cfg,
+	}
+	if cfg.failureRate > 0 || cb.detectSlowCalls() {
+		if cfg.windowSpan > 0 {
+			cb.window = newTimeWindow(cfg.windowSpan, cfg.windowBuckets)
+		} else {
+			cb.window = newCountWindow(cfg.windowSize)
 		}
-		m.RUnlock()
+	}
 
-		response, err := circuit(ctx)
+	return cb
+}
 
-		m.Lock()
-		defer m.Unlock()
+// Execute calls the wrapped circuit if the breaker allows it, otherwise it
+// returns ErrCircuitOpen.
+func (cb *CircuitBreaker[T]) Execute(ctx context.Context) (*T, error) {
+	if cb.cfg.store != nil {
+		cb.pull(ctx)
+	}
 
-		lastAttempt = time.Now()
-		if err != nil {
-			consecutiveFailures++
-			return response, err
+	generation, err := cb.before()
+	if err != nil {
+		return nil, err
+	}
+
+	start := cb.cfg.clock.Now()
+	defer func() {
+		if e := recover(); e != nil {
+			cb.finish(ctx, generation, fmt.Errorf("breaker: panic: %v", e), cb.cfg.clock.Now().Sub(start))
+			panic(e)
+		}
+	}()
+
+	response, err := cb.circuit(ctx)
+	cb.finish(ctx, generation, err, cb.cfg.clock.Now().Sub(start))
+
+	return response, err
+}
+
+func (cb *CircuitBreaker[T]) finish(ctx context.Context, generation uint64, err error, elapsed time.Duration) {
+	outcome := cb.after(generation, err, elapsed)
+	if cb.cfg.store != nil {
+		cb.push(ctx, outcome)
+	}
+}
+
+// Circuit returns the breaker as a circuit function.
+func (cb *CircuitBreaker[T]) Circuit() Circuit[T] {
+	return cb.Execute
+}
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker[T]) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	state, _ := cb.currentState(cb.cfg.clock.Now())
+	return state
+}
+
+// Counts returns the call counts collected since the breaker last changed
+// state.
+func (cb *CircuitBreaker[T]) Counts() BreakerCounts {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	now := cb.cfg.clock.Now()
+	cb.currentState(now)
+	return cb.currentCounts(now)
+}
+
+func (cb *CircuitBreaker[T]) currentCounts(now time.Time) BreakerCounts {
+	counts := cb.counts
+	if cb.window != nil {
+		totals := cb.window.totals(now)
+		counts.WindowCalls = totals.calls
+		counts.WindowFailures = totals.failures
+		counts.WindowSlowCalls = totals.slow
+	}
+	return counts
+}
+
+func (cb *CircuitBreaker[T]) before() (uint64, error) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	now := cb.cfg.clock.Now()
+	state, generation := cb.currentState(now)
+	switch state {
+	case StateOpen:
+		cb.record(EventRejected, state, state, now)
+		return generation, ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.counts.Requests >= uint32(cb.cfg.halfOpenProbes) {
+			cb.record(EventRejected, state, state, now)
+			return generation, ErrCircuitOpen
 		}
+	}
 
-		consecutiveFailures = 0
-		return response, nil
+	cb.counts.onRequest()
+	return generation, nil
+}
+
+func (cb *CircuitBreaker[T]) after(before uint64, err error, elapsed time.Duration) breakerOutcome {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	now := cb.cfg.clock.Now()
+	state, generation := cb.currentState(now)
+	// the outcome of a call started in a previous state is stale
+	if generation != before {
+		return breakerOutcome{}
 	}
+
+	if err != nil && !cb.cfg.isFailure(err) {
+		// give the probe slot back so that another call can decide
+		if state == StateHalfOpen {
+			cb.counts.Requests--
+		}
+		return breakerOutcome{}
+	}
+
+	slow := cb.detectSlowCalls() && elapsed > cb.cfg.slowCallDuration
+	if slow {
+		cb.counts.TotalSlowCalls++
+	}
+	if err != nil {
+		cb.onFailure(state, now, slow)
+	} else {
+		cb.onSuccess(state, now, slow)
+	}
+
+	return breakerOutcome{
+		counted: true,
+		failure: err != nil,
+		from:    state,
+		record:  cb.localRecord(),
+	}
+}
+
+func (cb *CircuitBreaker[T]) onSuccess(state BreakerState, now time.Time, slow bool) {
+	switch state {
+	case StateClosed:
+		cb.counts.onSuccess()
+		if cb.window != nil {
+			cb.window.record(now, false, slow)
+		}
+		if slow && !cb.forced && cb.readyToTrip(now) {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		if slow {
+			cb.counts.onFailure()
+			cb.setState(StateOpen, now)
+			return
+		}
+		cb.counts.onSuccess()
+		if cb.counts.ConsecutiveSuccesses >= uint32(cb.cfg.successThreshold) {
+			cb.setState(StateClosed, now)
+		}
+	}
+}
+
+func (cb *CircuitBreaker[T]) onFailure(state BreakerState, now time.Time, slow bool) {
+	cb.counts.onFailure()
+	switch state {
+	case StateClosed:
+		if cb.window != nil {
+			cb.window.record(now, true, slow)
+		}
+		if !cb.forced && cb.readyToTrip(now) {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		cb.setState(StateOpen, now)
+	}
+}
+
+func (cb *CircuitBreaker[T]) detectSlowCalls() bool {
+	return cb.cfg.slowCallDuration > 0 && cb.cfg.slowCallRate > 0
+}
+
+func (cb *CircuitBreaker[T]) readyToTrip(now time.Time) bool {
+	if cb.cfg.failureRate <= 0 && cb.counts.ConsecutiveFailures >= uint32(cb.cfg.failureThreshold) {
+		return true
+	}
+	if cb.window == nil {
+		return false
+	}
+
+	totals := cb.window.totals(now)
+	if totals.calls < uint32(cb.cfg.minimumCalls) {
+		return false
+	}
+	if cb.cfg.failureRate > 0 && float64(totals.failures)*100 >= cb.cfg.failureRate*float64(totals.calls) {
+		return true
+	}
+	return cb.detectSlowCalls() && float64(totals.slow)*100 >= cb.cfg.slowCallRate*float64(totals.calls)
+}
+
+// currentState moves an open breaker to half-open once its timeout expires,
+// unless it has been forced open, and returns the resulting state along with
+// its generation.
+func (cb *CircuitBreaker[T]) currentState(now time.Time) (BreakerState, uint64) {
+	if cb.state == StateOpen && !cb.forced && !now.Before(cb.openUntil) {
+		cb.setState(StateHalfOpen, now)
+	}
+	return cb.state, cb.generation
+}
+
+func (cb *CircuitBreaker[T]) setState(state BreakerState, now time.Time) {
+	if cb.state == state {
+		return
+	}
+
+	cb.record(eventTypeFor(state), cb.state, state, now)
+	cb.state = state
+	cb.generation++
+	cb.counts = BreakerCounts{}
+	if cb.window != nil {
+		cb.window.reset()
+	}
+
+	switch state {
+	case StateClosed:
+		cb.trips = 0
+		cb.openFor = 0
+		cb.openUntil = time.Time{}
+	case StateOpen:
+		cb.trips++
+		cb.openFor = cb.cfg.backoff.Next(cb.trips, cb.openFor)
+		cb.openUntil = now.Add(cb.openFor)
+	}
+}
+
+// Breaker function represents a circuit breaker. It wraps a circuit function
+// and returns a new circuit function that will return ErrCircuitOpen if the
+// circuit function fails more than failureThreshold times in a row. Further
+// options, such as WithBackoff, are passed on to NewCircuitBreaker.
+func Breaker[T any](circuit Circuit[T], failureThreshold uint, opts ...BreakerOption) Circuit[T] {
+	opts = append([]BreakerOption{WithFailureThreshold(failureThreshold)}, opts...)
+	return NewCircuitBreaker(circuit, opts...).Execute
 }
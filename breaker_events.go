@@ -0,0 +1,148 @@
+package dist
+
+import (
+	"fmt"
+	"time"
+)
+
+// BreakerEventType tells what happened to a circuit breaker.
+type BreakerEventType int
+
+const (
+	// EventTripped is sent when the circuit opens.
+	EventTripped BreakerEventType = iota
+	// EventHalfOpen is sent when the open timeout expires and the circuit
+	// starts letting probe calls through.
+	EventHalfOpen
+	// EventClosed is sent when the circuit closes again.
+	EventClosed
+	// EventRejected is sent when a call is rejected with ErrCircuitOpen.
+	EventRejected
+)
+
+func (t BreakerEventType) String() string {
+	switch t {
+	case EventTripped:
+		return "tripped"
+	case EventHalfOpen:
+		return "half-open"
+	case EventClosed:
+		return "closed"
+	case EventRejected:
+		return "rejected"
+	default:
+		return fmt.Sprintf("unknown event %d", int(t))
+	}
+}
+
+// BreakerEvent describes a state change of a circuit breaker, or a call it
+// rejected. From and To are equal for rejected calls.
+type BreakerEvent struct {
+	Name string
+	Type BreakerEventType
+	From BreakerState
+	To   BreakerState
+	Time time.Time
+}
+
+// StateChangeFunc is called after a circuit breaker moves from one state to
+// another.
+type StateChangeFunc func(name string, from BreakerState, to BreakerState)
+
+// WithName names the breaker. The name is passed to state change callbacks
+// and set on every event the breaker sends.
+func WithName(name string) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.name = name
+	})
+}
+
+// WithOnStateChange registers a callback invoked after every state change.
+// The callback runs on the goroutine whose call caused the change, after the
+// breaker has been unlocked, so it may call back into the breaker.
+func WithOnStateChange(fn StateChangeFunc) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.onStateChange = fn
+	})
+}
+
+// Name returns the name of the breaker.
+func (cb *CircuitBreaker[T]) Name() string {
+	return cb.cfg.name
+}
+
+// Subscribe returns a channel receiving the events of the breaker, buffered to
+// hold up to buffer events, and a function that cancels the subscription and
+// closes the channel. The breaker never blocks on a subscriber: events that do
+// not fit in the buffer are dropped.
+func (cb *CircuitBreaker[T]) Subscribe(buffer int) (<-chan BreakerEvent, func()) {
+	ch := make(chan BreakerEvent, buffer)
+
+	cb.subMu.Lock()
+	if cb.subs == nil {
+		cb.subs = make(map[chan BreakerEvent]struct{})
+	}
+	cb.subs[ch] = struct{}{}
+	cb.subMu.Unlock()
+
+	var unsubscribed bool
+	return ch, func() {
+		cb.subMu.Lock()
+		defer cb.subMu.Unlock()
+
+		if unsubscribed {
+			return
+		}
+		unsubscribed = true
+		delete(cb.subs, ch)
+		close(ch)
+	}
+}
+
+// record queues an event to be sent once the breaker is unlocked. It must be
+// called with the breaker locked.
+func (cb *CircuitBreaker[T]) record(typ BreakerEventType, from BreakerState, to BreakerState, now time.Time) {
+	cb.pending = append(cb.pending, BreakerEvent{
+		Name: cb.cfg.name,
+		Type: typ,
+		From: from,
+		To:   to,
+		Time: now,
+	})
+}
+
+// unlock unlocks the breaker and sends the events queued while it was locked.
+func (cb *CircuitBreaker[T]) unlock() {
+	events := cb.pending
+	cb.pending = nil
+	cb.mu.Unlock()
+
+	for _, e := range events {
+		if e.Type != EventRejected && cb.cfg.onStateChange != nil {
+			cb.cfg.onStateChange(e.Name, e.From, e.To)
+		}
+	}
+
+	cb.subMu.Lock()
+	defer cb.subMu.Unlock()
+
+	for _, e := range events {
+		for ch := range cb.subs {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+func eventTypeFor(state BreakerState) BreakerEventType {
+	switch state {
+	case StateOpen:
+		return EventTripped
+	case StateHalfOpen:
+		return EventHalfOpen
+	default:
+		return EventClosed
+	}
+}
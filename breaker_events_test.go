@@ -0,0 +1,87 @@
+package dist_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestBreakerOnStateChange(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+
+	var mu sync.Mutex
+	var transitions []string
+	var cb *dist.CircuitBreaker[string]
+	cb = dist.NewCircuitBreaker[string](fc.call,
+		dist.WithClock(clock),
+		dist.WithName("upstream"),
+		dist.WithFailureThreshold(1),
+		dist.WithOpenTimeout(time.Millisecond*30),
+		dist.WithOnStateChange(func(name string, from dist.BreakerState, to dist.BreakerState) {
+			mu.Lock()
+			defer mu.Unlock()
+			// calling back into the breaker must not deadlock
+			_ = cb.State()
+			transitions = append(transitions, name+":"+from.String()+"->"+to.String())
+		}),
+	)
+
+	_, _ = cb.Execute(context.Background())
+	clock.Advance(time.Millisecond * 40)
+	fc.fail.Store(false)
+	_, _ = cb.Execute(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"upstream:closed->open",
+		"upstream:open->half-open",
+		"upstream:half-open->closed",
+	}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, transitions)
+		}
+	}
+}
+
+func TestBreakerSubscribe(t *testing.T) {
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](fc.call,
+		dist.WithName("upstream"),
+		dist.WithFailureThreshold(1),
+	)
+
+	events, unsubscribe := cb.Subscribe(10)
+	_, _ = cb.Execute(context.Background())
+	_, _ = cb.Execute(context.Background())
+	unsubscribe()
+	unsubscribe()
+
+	var received []dist.BreakerEvent
+	for e := range events {
+		received = append(received, e)
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected 2 events, got %v", received)
+	}
+	if received[0].Type != dist.EventTripped || received[0].From != dist.StateClosed || received[0].To != dist.StateOpen {
+		t.Errorf("unexpected event %+v", received[0])
+	}
+	if received[1].Type != dist.EventRejected || received[1].Name != "upstream" {
+		t.Errorf("unexpected event %+v", received[1])
+	}
+
+	// the breaker does not block on subscribers that stopped reading
+	_, _ = cb.Subscribe(0)
+	_, _ = cb.Execute(context.Background())
+}
@@ -0,0 +1,214 @@
+package dist
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownBreaker is returned by a registry asked for a breaker that has not
+// been registered.
+var ErrUnknownBreaker = errors.New("registry: unknown breaker")
+
+// ErrBreakerTypeMismatch is returned by GetOrCreateBreaker when a breaker with
+// the requested name exists but wraps a circuit of a different type.
+var ErrBreakerTypeMismatch = errors.New("registry: breaker has a different type")
+
+// BreakerSnapshot is a point in time view of a circuit breaker.
+type BreakerSnapshot struct {
+	Name   string
+	State  BreakerState
+	Counts BreakerCounts
+	// NextRetry is when an open circuit turns half-open. It is zero unless
+	// the circuit is open, and stays zero while it is forced open.
+	NextRetry time.Time
+	// Forced reports whether the state was set by ForceOpen or ForceClose.
+	Forced bool
+}
+
+// ManagedBreaker is the part of a circuit breaker that does not depend on the
+// type of its circuit, which lets a registry hold breakers of any type.
+type ManagedBreaker interface {
+	Name() string
+	State() BreakerState
+	Counts() BreakerCounts
+	Snapshot() BreakerSnapshot
+	ForceOpen()
+	ForceClose()
+	Reset()
+}
+
+// Snapshot returns the name, state, counts and next retry time of the breaker.
+func (cb *CircuitBreaker[T]) Snapshot() BreakerSnapshot {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	now := cb.cfg.clock.Now()
+	state, _ := cb.currentState(now)
+	snapshot := BreakerSnapshot{
+		Name:   cb.cfg.name,
+		State:  state,
+		Counts: cb.currentCounts(now),
+		Forced: cb.forced,
+	}
+	if state == StateOpen && !cb.forced {
+		snapshot.NextRetry = cb.openUntil
+	}
+	return snapshot
+}
+
+// ForceOpen opens the circuit and keeps it open, rejecting all calls, until
+// ForceClose or Reset is called.
+func (cb *CircuitBreaker[T]) ForceOpen() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.forced = true
+	cb.setState(StateOpen, cb.cfg.clock.Now())
+}
+
+// ForceClose closes the circuit and keeps it closed, letting all calls
+// through whatever their outcome, until ForceOpen or Reset is called.
+func (cb *CircuitBreaker[T]) ForceClose() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.forced = true
+	cb.setState(StateClosed, cb.cfg.clock.Now())
+}
+
+// Reset lifts any forced state and returns the breaker to a closed circuit
+// with all its counts cleared.
+func (cb *CircuitBreaker[T]) Reset() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.forced = false
+	if cb.state != StateClosed {
+		cb.setState(StateClosed, cb.cfg.clock.Now())
+		return
+	}
+
+	cb.generation++
+	cb.counts = BreakerCounts{}
+	if cb.window != nil {
+		cb.window.reset()
+	}
+}
+
+// BreakerRegistry keeps circuit breakers by name so that they can be listed,
+// inspected and overridden during incidents.
+type BreakerRegistry struct {
+	mu       sync.RWMutex
+	breakers map[string]ManagedBreaker
+	opts     []BreakerOption
+}
+
+// NewBreakerRegistry returns an empty registry. The given options are applied
+// to every breaker the registry creates, before the options passed to
+// GetOrCreateBreaker.
+func NewBreakerRegistry(opts ...BreakerOption) *BreakerRegistry {
+	return &BreakerRegistry{
+		breakers: make(map[string]ManagedBreaker),
+		opts:     opts,
+	}
+}
+
+// GetOrCreateBreaker returns the breaker registered under name, or creates and
+// registers a new one wrapping circuit. An existing breaker keeps wrapping the
+// circuit it was created with.
+func GetOrCreateBreaker[T any](r *BreakerRegistry, name string, circuit Circuit[T], opts ...BreakerOption) (*CircuitBreaker[T], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if mb, ok := r.breakers[name]; ok {
+		cb, ok := mb.(*CircuitBreaker[T])
+		if !ok {
+			return nil, ErrBreakerTypeMismatch
+		}
+		return cb, nil
+	}
+
+	all := make([]BreakerOption, 0, len(r.opts)+len(opts)+1)
+	all = append(all, r.opts...)
+	all = append(all, opts...)
+	all = append(all, WithName(name))
+
+	cb := NewCircuitBreaker(circuit, all...)
+	r.breakers[name] = cb
+	return cb, nil
+}
+
+// Get returns the breaker registered under name.
+func (r *BreakerRegistry) Get(name string) (ManagedBreaker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mb, ok := r.breakers[name]
+	return mb, ok
+}
+
+// Remove unregisters the breaker registered under name.
+func (r *BreakerRegistry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.breakers, name)
+}
+
+// Names returns the sorted names of all registered breakers.
+func (r *BreakerRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.breakers))
+	for name := range r.breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snapshots returns snapshots of all registered breakers, sorted by name.
+func (r *BreakerRegistry) Snapshots() []BreakerSnapshot {
+	r.mu.RLock()
+	breakers := make([]ManagedBreaker, 0, len(r.breakers))
+	for _, mb := range r.breakers {
+		breakers = append(breakers, mb)
+	}
+	r.mu.RUnlock()
+
+	snapshots := make([]BreakerSnapshot, 0, len(breakers))
+	for _, mb := range breakers {
+		snapshots = append(snapshots, mb.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots
+}
+
+// ForceOpen forces the breaker registered under name open.
+func (r *BreakerRegistry) ForceOpen(name string) error {
+	return r.apply(name, ManagedBreaker.ForceOpen)
+}
+
+// ForceClose forces the breaker registered under name closed.
+func (r *BreakerRegistry) ForceClose(name string) error {
+	return r.apply(name, ManagedBreaker.ForceClose)
+}
+
+// Reset resets the breaker registered under name.
+func (r *BreakerRegistry) Reset(name string) error {
+	return r.apply(name, ManagedBreaker.Reset)
+}
+
+func (r *BreakerRegistry) apply(name string, fn func(ManagedBreaker)) error {
+	mb, ok := r.Get(name)
+	if !ok {
+		return ErrUnknownBreaker
+	}
+	fn(mb)
+	return nil
+}
@@ -0,0 +1,127 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestBreakerRegistryGetOrCreate(t *testing.T) {
+	r := dist.NewBreakerRegistry(dist.WithFailureThreshold(1))
+
+	fc := &flakyCircuit{}
+	cb, err := dist.GetOrCreateBreaker[string](r, "users", fc.call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cb.Name() != "users" {
+		t.Errorf("expected name users, got %q", cb.Name())
+	}
+
+	again, err := dist.GetOrCreateBreaker[string](r, "users", fc.call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != cb {
+		t.Error("expected the registered breaker to be returned")
+	}
+
+	other := func(ctx context.Context) (*int, error) {
+		return nil, nil
+	}
+	if _, err := dist.GetOrCreateBreaker[int](r, "users", other); !errors.Is(err, dist.ErrBreakerTypeMismatch) {
+		t.Errorf("expected ErrBreakerTypeMismatch, got %v", err)
+	}
+
+	if _, err := dist.GetOrCreateBreaker[int](r, "orders", other); err != nil {
+		t.Fatal(err)
+	}
+	names := r.Names()
+	if len(names) != 2 || names[0] != "orders" || names[1] != "users" {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	r.Remove("orders")
+	if _, ok := r.Get("orders"); ok {
+		t.Error("expected orders to be removed")
+	}
+}
+
+func TestBreakerRegistrySnapshots(t *testing.T) {
+	r := dist.NewBreakerRegistry(
+		dist.WithFailureThreshold(1),
+		dist.WithOpenTimeout(time.Minute),
+	)
+
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	a, _ := dist.GetOrCreateBreaker[string](r, "a", failing.call)
+	b, _ := dist.GetOrCreateBreaker[string](r, "b", (&flakyCircuit{}).call)
+
+	before := time.Now()
+	_, _ = a.Execute(context.Background())
+	_, _ = b.Execute(context.Background())
+
+	snapshots := r.Snapshots()
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].Name != "a" || snapshots[0].State != dist.StateOpen {
+		t.Errorf("unexpected snapshot %+v", snapshots[0])
+	}
+	if snapshots[0].NextRetry.Before(before.Add(time.Minute)) {
+		t.Errorf("unexpected next retry %s", snapshots[0].NextRetry)
+	}
+	if snapshots[1].Name != "b" || snapshots[1].State != dist.StateClosed || snapshots[1].Counts.TotalSuccesses != 1 {
+		t.Errorf("unexpected snapshot %+v", snapshots[1])
+	}
+	if !snapshots[1].NextRetry.IsZero() {
+		t.Errorf("expected no next retry, got %s", snapshots[1].NextRetry)
+	}
+}
+
+func TestBreakerRegistryOverrides(t *testing.T) {
+	r := dist.NewBreakerRegistry(dist.WithFailureThreshold(1))
+
+	fc := &flakyCircuit{}
+	cb, _ := dist.GetOrCreateBreaker[string](r, "users", fc.call)
+
+	if err := r.ForceOpen("users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cb.Execute(context.Background()); !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if s := cb.Snapshot(); !s.Forced || s.State != dist.StateOpen || !s.NextRetry.IsZero() {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+
+	fc.fail.Store(true)
+	if err := r.ForceClose("users"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+
+	if err := r.Reset("users"); err != nil {
+		t.Fatal(err)
+	}
+	if s := cb.Snapshot(); s.Forced || s.Counts.Requests != 0 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+
+	if err := r.ForceOpen("missing"); !errors.Is(err, dist.ErrUnknownBreaker) {
+		t.Errorf("expected ErrUnknownBreaker, got %v", err)
+	}
+}
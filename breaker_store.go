@@ -0,0 +1,306 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrStoreContention is returned when a breaker gives up updating its shared
+// record because other replicas kept changing it.
+var ErrStoreContention = errors.New("breaker: store contention")
+
+const maxStoreAttempts = 10
+
+// BreakerRecord is the state of a circuit breaker shared by all replicas
+// through a BreakerStore.
+type BreakerRecord struct {
+	State BreakerState
+	// Failures is the number of consecutive failures seen by all replicas
+	// while the circuit is closed.
+	Failures  uint32
+	Trips     uint
+	OpenFor   time.Duration
+	OpenUntil time.Time
+}
+
+// Equal reports whether both records hold the same state.
+func (r BreakerRecord) Equal(o BreakerRecord) bool {
+	return r.State == o.State &&
+		r.Failures == o.Failures &&
+		r.Trips == o.Trips &&
+		r.OpenFor == o.OpenFor &&
+		r.OpenUntil.Equal(o.OpenUntil)
+}
+
+// BreakerStore keeps breaker records by breaker name. Loading a name that has
+// never been stored returns the zero record, that is a closed circuit.
+type BreakerStore interface {
+	Load(ctx context.Context, name string) (BreakerRecord, error)
+	// CompareAndSwap stores new under name if the record stored there is
+	// equal to old, and reports whether it did.
+	CompareAndSwap(ctx context.Context, name string, old BreakerRecord, new BreakerRecord) (bool, error)
+}
+
+// WithStore makes the breaker share its state with every other breaker of
+// the same name using the same store, typically the same breaker in other
+// replicas of a process. A replica that trips the circuit opens it for all of
+// them, and in consecutive-failure mode the failures seen by all replicas add
+// up. Rolling windows are kept per replica. The breaker loads its record
+// before every call and updates it when a call changes it; if the store
+// fails or does not answer within the store timeout, the breaker carries on
+// with its local state alone. The record is kept under the name of the
+// breaker, so NewCircuitBreaker panics if WithStore is used without WithName.
+func WithStore(store BreakerStore) BreakerOption {
+	return breakerOptionFunc(func(c *breakerConfig) {
+		c.store = store
+	})
+}
+
+// breakerOutcome tells a store-backed breaker what a call did to its state.
+type breakerOutcome struct {
+	counted bool
+	failure bool
+	from    BreakerState
+	record  BreakerRecord
+}
+
+func (cb *CircuitBreaker[T]) localRecord() BreakerRecord {
+	return BreakerRecord{
+		State:     cb.state,
+		Failures:  cb.counts.ConsecutiveFailures,
+		Trips:     cb.trips,
+		OpenFor:   cb.openFor,
+		OpenUntil: cb.openUntil,
+	}
+}
+
+// pull brings the local state in line with the shared record.
+func (cb *CircuitBreaker[T]) pull(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, cb.cfg.storeTimeout)
+	defer cancel()
+
+	rec, err := cb.cfg.store.Load(ctx, cb.cfg.name)
+	if err != nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.adopt(rec, cb.cfg.clock.Now())
+}
+
+// adopt opens the circuit if another replica opened it for longer, and closes
+// a half-open circuit if another replica closed it. A local circuit that is
+// still open waits for its own timeout, so that it is never closed by a
+// record that has not yet seen its trip.
+func (cb *CircuitBreaker[T]) adopt(rec BreakerRecord, now time.Time) {
+	if cb.forced {
+		return
+	}
+
+	state, _ := cb.currentState(now)
+	switch rec.State {
+	case StateOpen:
+		if !now.Before(rec.OpenUntil) {
+			return
+		}
+		if state == StateOpen && !cb.openUntil.Before(rec.OpenUntil) {
+			return
+		}
+		cb.setState(StateOpen, now)
+		cb.trips = rec.Trips
+		cb.openFor = rec.OpenFor
+		cb.openUntil = rec.OpenUntil
+	case StateClosed:
+		if state == StateHalfOpen {
+			cb.setState(StateClosed, now)
+		}
+	}
+}
+
+// push publishes the outcome of a call to the shared record.
+func (cb *CircuitBreaker[T]) push(ctx context.Context, o breakerOutcome) {
+	if !o.counted {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cb.cfg.storeTimeout)
+	defer cancel()
+
+	switch {
+	case o.record.State == StateOpen && o.from != StateOpen:
+		_, _ = cb.update(ctx, func(rec *BreakerRecord) bool {
+			if rec.State == StateOpen && !rec.OpenUntil.Before(o.record.OpenUntil) {
+				return false
+			}
+			*rec = o.record
+			rec.Failures = 0
+			return true
+		})
+	case o.record.State == StateClosed && o.from == StateHalfOpen:
+		_, _ = cb.update(ctx, func(rec *BreakerRecord) bool {
+			if rec.Equal(BreakerRecord{}) {
+				return false
+			}
+			*rec = BreakerRecord{}
+			return true
+		})
+	case o.record.State == StateClosed && cb.cfg.failureRate <= 0:
+		now := cb.cfg.clock.Now()
+		rec, err := cb.update(ctx, func(rec *BreakerRecord) bool {
+			if !o.failure {
+				if rec.Failures == 0 {
+					return false
+				}
+				rec.Failures = 0
+				return true
+			}
+			if rec.State == StateOpen && now.Before(rec.OpenUntil) {
+				return false
+			}
+
+			rec.State = StateClosed
+			rec.Failures++
+			if rec.Failures >= uint32(cb.cfg.failureThreshold) {
+				rec.State = StateOpen
+				rec.Failures = 0
+				rec.Trips++
+				rec.OpenFor = cb.cfg.backoff.Next(rec.Trips, rec.OpenFor)
+				rec.OpenUntil = now.Add(rec.OpenFor)
+			}
+			return true
+		})
+		if err == nil && rec.State == StateOpen {
+			cb.mu.Lock()
+			cb.adopt(rec, now)
+			cb.unlock()
+		}
+	}
+}
+
+// update applies fn to the shared record until it is stored without another
+// replica changing the record in between. fn returns false if the record
+// needs no change.
+func (cb *CircuitBreaker[T]) update(ctx context.Context, fn func(*BreakerRecord) bool) (BreakerRecord, error) {
+	for i := 0; i < maxStoreAttempts; i++ {
+		old, err := cb.cfg.store.Load(ctx, cb.cfg.name)
+		if err != nil {
+			return BreakerRecord{}, err
+		}
+
+		rec := old
+		if !fn(&rec) {
+			return old, nil
+		}
+
+		swapped, err := cb.cfg.store.CompareAndSwap(ctx, cb.cfg.name, old, rec)
+		if err != nil {
+			return BreakerRecord{}, err
+		}
+		if swapped {
+			return rec, nil
+		}
+	}
+
+	return BreakerRecord{}, ErrStoreContention
+}
+
+// MemoryBreakerStore is a BreakerStore keeping its records in memory. It
+// shares state between breakers of the same process, or between processes
+// when served with ServeBreakerStore.
+type MemoryBreakerStore struct {
+	mu      sync.Mutex
+	records map[string]BreakerRecord
+}
+
+func NewMemoryBreakerStore() *MemoryBreakerStore {
+	return &MemoryBreakerStore{
+		records: make(map[string]BreakerRecord),
+	}
+}
+
+func (s *MemoryBreakerStore) Load(_ context.Context, name string) (BreakerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records[name], nil
+}
+
+func (s *MemoryBreakerStore) CompareAndSwap(_ context.Context, name string, old BreakerRecord, new BreakerRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.records[name].Equal(old) {
+		return false, nil
+	}
+	s.records[name] = new
+	return true, nil
+}
+
+// breakerSwap is an alias of an unnamed struct because net/rpc only accepts
+// exported or unnamed argument types.
+type breakerSwap = struct {
+	Name string
+	Old  BreakerRecord
+	New  BreakerRecord
+}
+
+// breakerStoreService exposes a BreakerStore over net/rpc.
+type breakerStoreService struct {
+	store BreakerStore
+}
+
+func (s *breakerStoreService) Load(name string, rec *BreakerRecord) error {
+	var err error
+	*rec, err = s.store.Load(context.Background(), name)
+	return err
+}
+
+func (s *breakerStoreService) CompareAndSwap(args breakerSwap, swapped *bool) error {
+	var err error
+	*swapped, err = s.store.CompareAndSwap(context.Background(), args.Name, args.Old, args.New)
+	return err
+}
+
+// ServeBreakerStore serves store to RemoteBreakerStore clients connecting to
+// l. It blocks until l stops accepting connections and returns the error that
+// stopped it.
+func ServeBreakerStore(l net.Listener, store BreakerStore) error {
+	return serveRPC(l, "BreakerStore", &breakerStoreService{store: store})
+}
+
+// RemoteBreakerStore is a BreakerStore served by ServeBreakerStore in another
+// process. It connects on first use and reconnects after the connection
+// breaks.
+type RemoteBreakerStore struct {
+	client *rpcClient
+}
+
+// NewRemoteBreakerStore returns a store talking to the server listening on the
+// given network address, for example "tcp" and "127.0.0.1:7070".
+func NewRemoteBreakerStore(network string, addr string) *RemoteBreakerStore {
+	return &RemoteBreakerStore{
+		client: newRPCClient(network, addr),
+	}
+}
+
+func (s *RemoteBreakerStore) Load(ctx context.Context, name string) (BreakerRecord, error) {
+	var rec BreakerRecord
+	err := s.client.call(ctx, "BreakerStore.Load", name, &rec)
+	return rec, err
+}
+
+func (s *RemoteBreakerStore) CompareAndSwap(ctx context.Context, name string, old BreakerRecord, new BreakerRecord) (bool, error) {
+	var swapped bool
+	err := s.client.call(ctx, "BreakerStore.CompareAndSwap", breakerSwap{Name: name, Old: old, New: new}, &swapped)
+	return swapped, err
+}
+
+// Close closes the connection to the server.
+func (s *RemoteBreakerStore) Close() error {
+	return s.client.close()
+}
@@ -0,0 +1,266 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestBreakerStoreSharesTrip(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	store := dist.NewMemoryBreakerStore()
+
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	healthy := &flakyCircuit{}
+
+	opts := []dist.BreakerOption{
+		dist.WithClock(clock),
+		dist.WithName("upstream"),
+		dist.WithStore(store),
+		dist.WithFailureThreshold(4),
+		dist.WithOpenTimeout(time.Millisecond * 50),
+	}
+	a := dist.NewCircuitBreaker[string](failing.call, opts...)
+	b := dist.NewCircuitBreaker[string](failing.call, opts...)
+	c := dist.NewCircuitBreaker[string](healthy.call, opts...)
+
+	// two failures on each replica add up to the shared threshold
+	for i := 0; i < 2; i++ {
+		_, _ = a.Execute(context.Background())
+		_, _ = b.Execute(context.Background())
+	}
+	if b.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+
+	// replica c has seen no failure but learns about the trip from the store
+	if _, err := c.Execute(context.Background()); !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if healthy.calls != 0 {
+		t.Fatalf("expected no calls, got %d", healthy.calls)
+	}
+
+	rec, err := store.Load(context.Background(), "upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.State != dist.StateOpen || rec.Trips != 1 {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+
+	// a successful probe on c closes the circuit for everyone
+	clock.Advance(time.Millisecond * 60)
+	if _, err := c.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rec, _ = store.Load(context.Background(), "upstream")
+	if !rec.Equal(dist.BreakerRecord{}) {
+		t.Fatalf("expected closed record, got %+v", rec)
+	}
+	failing.fail.Store(false)
+	if _, err := b.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", b.State())
+	}
+}
+
+func TestRemoteBreakerStore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- dist.ServeBreakerStore(l, dist.NewMemoryBreakerStore())
+	}()
+
+	remoteA := dist.NewRemoteBreakerStore("tcp", l.Addr().String())
+	defer remoteA.Close()
+	remoteB := dist.NewRemoteBreakerStore("tcp", l.Addr().String())
+	defer remoteB.Close()
+
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	healthy := &flakyCircuit{}
+
+	a := dist.NewCircuitBreaker[string](failing.call,
+		dist.WithName("upstream"),
+		dist.WithStore(remoteA),
+		dist.WithFailureThreshold(1),
+		dist.WithOpenTimeout(time.Minute),
+	)
+	b := dist.NewCircuitBreaker[string](healthy.call,
+		dist.WithName("upstream"),
+		dist.WithStore(remoteB),
+	)
+
+	_, _ = a.Execute(context.Background())
+	if _, err := b.Execute(context.Background()); !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	rec, err := remoteB.Load(context.Background(), "upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.State != dist.StateOpen || rec.OpenUntil.Before(time.Now().Add(time.Second*50)) {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+
+	_ = l.Close()
+	if err := <-done; err == nil {
+		t.Fatal("expected server to stop with an error")
+	}
+}
+
+func TestBreakerStoreUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	remote := dist.NewRemoteBreakerStore("tcp", addr)
+	defer remote.Close()
+	if _, err := remote.Load(context.Background(), "upstream"); err == nil {
+		t.Fatal("expected an error from an unreachable store")
+	}
+
+	// the breaker carries on with its local state
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](failing.call,
+		dist.WithName("upstream"),
+		dist.WithStore(remote),
+		dist.WithFailureThreshold(2),
+	)
+	_, _ = cb.Execute(context.Background())
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+}
+
+// hangingBreakerStore never answers until its context ends.
+type hangingBreakerStore struct{}
+
+func (hangingBreakerStore) Load(ctx context.Context, _ string) (dist.BreakerRecord, error) {
+	<-ctx.Done()
+	return dist.BreakerRecord{}, ctx.Err()
+}
+
+func (hangingBreakerStore) CompareAndSwap(ctx context.Context, _ string, _ dist.BreakerRecord, _ dist.BreakerRecord) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestBreakerStoreTimeout(t *testing.T) {
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](failing.call,
+		dist.WithName("upstream"),
+		dist.WithStore(hangingBreakerStore{}),
+		dist.WithStoreTimeout(time.Millisecond*10),
+		dist.WithFailureThreshold(1),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := cb.Execute(ctx)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the circuit error, got %v", err)
+	}
+	if time.Since(start) > time.Millisecond*500 {
+		t.Fatalf("expected a hanging store to be given up on, took %v", time.Since(start))
+	}
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected the local state to trip, got %s", cb.State())
+	}
+}
+
+func TestBreakerStoreRequiresName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a store without a name to be rejected")
+		}
+	}()
+	dist.NewCircuitBreaker[string](mockEffector, dist.WithStore(dist.NewMemoryBreakerStore()))
+}
+
+// slowBreakerStore answers loads of some keys after a delay.
+type slowBreakerStore struct {
+	dist.BreakerStore
+	delays map[string]time.Duration
+}
+
+func (s slowBreakerStore) Load(ctx context.Context, name string) (dist.BreakerRecord, error) {
+	time.Sleep(s.delays[name])
+	return s.BreakerStore.Load(ctx, name)
+}
+
+func TestRemoteBreakerStoreAbandonedCall(t *testing.T) {
+	store := dist.NewMemoryBreakerStore()
+	for i, name := range []string{"slow", "medium", "fast"} {
+		if _, err := store.CompareAndSwap(context.Background(), name, dist.BreakerRecord{}, dist.BreakerRecord{Trips: uint(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		_ = dist.ServeBreakerStore(l, slowBreakerStore{store, map[string]time.Duration{
+			"slow":   time.Millisecond * 30,
+			"medium": time.Millisecond * 50,
+		}})
+	}()
+
+	remote := dist.NewRemoteBreakerStore("tcp", l.Addr().String())
+	defer remote.Close()
+	if _, err := remote.Load(context.Background(), "fast"); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		rec dist.BreakerRecord
+		err error
+	}
+	medium := make(chan result, 1)
+	go func() {
+		rec, err := remote.Load(context.Background(), "medium")
+		medium <- result{rec, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err := remote.Load(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the slow load to be abandoned, got %v", err)
+	}
+
+	// giving up on one call leaves the others on the connection alone
+	r := <-medium
+	if r.err != nil || r.rec.Trips != 2 {
+		t.Fatalf("expected the concurrent load to complete, got %+v, %v", r.rec, r.err)
+	}
+
+	// the late reply to the abandoned call is dropped, not handed to the
+	// next caller
+	time.Sleep(time.Millisecond * 30)
+	rec, err := remote.Load(context.Background(), "fast")
+	if err != nil || rec.Trips != 3 {
+		t.Fatalf("expected the record of fast, got %+v, %v", rec, err)
+	}
+}
@@ -36,3 +36,320 @@ func TestBreaker(t *testing.T) {
 	}
 	mockCircuitCalled = 0
 }
+
+type flakyCircuit struct {
+	calls int64
+	fail  atomic.Bool
+}
+
+func (fc *flakyCircuit) call(ctx context.Context) (*string, error) {
+	atomic.AddInt64(&fc.calls, 1)
+	if fc.fail.Load() {
+		return nil, errors.New("flakyCircuit")
+	}
+	result := "flakyCircuit"
+	return &result, nil
+}
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](fc.call,
+		dist.WithClock(clock),
+		dist.WithFailureThreshold(3),
+		dist.WithOpenTimeout(time.Millisecond*50),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+
+	_, err := cb.Execute(context.Background())
+	if !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if fc.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", fc.calls)
+	}
+
+	clock.Advance(time.Millisecond * 60)
+	if cb.State() != dist.StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", cb.State())
+	}
+
+	fc.fail.Store(false)
+	if _, err := cb.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+	if counts := cb.Counts(); counts.Requests != 0 {
+		t.Fatalf("expected counts to be reset, got %+v", counts)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](fc.call,
+		dist.WithClock(clock),
+		dist.WithFailureThreshold(1),
+		dist.WithOpenTimeout(time.Millisecond*50),
+		dist.WithHalfOpenProbes(3),
+		dist.WithSuccessThreshold(2),
+	)
+
+	_, _ = cb.Execute(context.Background())
+	clock.Advance(time.Millisecond * 60)
+
+	fc.fail.Store(false)
+	if _, err := cb.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cb.State() != dist.StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", cb.State())
+	}
+	if counts := cb.Counts(); counts.ConsecutiveSuccesses != 1 {
+		t.Fatalf("expected 1 consecutive success, got %+v", counts)
+	}
+	if _, err := cb.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](fc.call,
+		dist.WithClock(clock),
+		dist.WithFailureThreshold(1),
+		dist.WithOpenTimeout(time.Millisecond*50),
+	)
+
+	_, _ = cb.Execute(context.Background())
+	clock.Advance(time.Millisecond * 60)
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+
+	// the second trip doubles the open timeout
+	clock.Advance(time.Millisecond * 60)
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+	clock.Advance(time.Millisecond * 50)
+	if cb.State() != dist.StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerOpenTimeoutCap(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](fc.call,
+		dist.WithClock(clock),
+		dist.WithFailureThreshold(1),
+		dist.WithOpenTimeout(time.Minute),
+	)
+
+	// the open timeout doubles from a minute but never exceeds five
+	for i := 0; i < 70; i++ {
+		_, _ = cb.Execute(context.Background())
+		if cb.State() != dist.StateOpen {
+			t.Fatalf("trip %d: expected open, got %s", i+1, cb.State())
+		}
+		clock.Advance(time.Minute * 5)
+		if cb.State() != dist.StateHalfOpen {
+			t.Fatalf("trip %d: expected half-open, got %s", i+1, cb.State())
+		}
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	var calls int64
+	// every other call fails, so there are never two failures in a row
+	circuit := func(ctx context.Context) (*string, error) {
+		if atomic.AddInt64(&calls, 1)%2 == 0 {
+			return nil, errors.New("intermittent")
+		}
+		result := "intermittent"
+		return &result, nil
+	}
+	cb := dist.NewCircuitBreaker[string](circuit,
+		dist.WithFailureRate(40),
+		dist.WithMinimumCalls(10),
+		dist.WithCountWindow(20),
+	)
+
+	for i := 0; i < 9; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed below minimum calls, got %s", cb.State())
+	}
+	if rate := cb.Counts().FailureRate(); rate < 40 || rate > 50 {
+		t.Fatalf("unexpected failure rate %f", rate)
+	}
+
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerTimeWindow(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	fc := &flakyCircuit{}
+	fc.fail.Store(true)
+	cb := dist.NewCircuitBreaker[string](fc.call,
+		dist.WithClock(clock),
+		dist.WithFailureRate(50),
+		dist.WithMinimumCalls(4),
+		dist.WithTimeWindow(time.Millisecond*100, 10),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	// the failures fall out of the window before the minimum is reached
+	clock.Advance(time.Millisecond * 150)
+	if counts := cb.Counts(); counts.WindowCalls != 0 {
+		t.Fatalf("expected empty window, got %+v", counts)
+	}
+
+	fc.fail.Store(false)
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	fc.fail.Store(true)
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+	_, _ = cb.Execute(context.Background())
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerSlowCalls(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var delay atomic.Int64
+	circuit := func(ctx context.Context) (*string, error) {
+		clock.Advance(time.Duration(delay.Load()))
+		result := "slow"
+		return &result, nil
+	}
+	cb := dist.NewCircuitBreaker[string](circuit,
+		dist.WithClock(clock),
+		dist.WithSlowCallThreshold(time.Millisecond*20, 50),
+		dist.WithMinimumCalls(4),
+		dist.WithCountWindow(4),
+		dist.WithOpenTimeout(time.Millisecond*50),
+	)
+
+	for i := 0; i < 2; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	delay.Store(int64(time.Millisecond * 30))
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+	counts := cb.Counts()
+	if counts.TotalSlowCalls != 1 || counts.WindowSlowCalls != 1 || counts.TotalFailures != 0 {
+		t.Fatalf("unexpected counts %+v", counts)
+	}
+
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+
+	// a slow probe reopens the circuit
+	clock.Advance(time.Millisecond * 60)
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresNonFailures(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	err := context.Canceled
+	circuit := func(ctx context.Context) (*string, error) {
+		return nil, err
+	}
+	cb := dist.NewCircuitBreaker[string](circuit,
+		dist.WithClock(clock),
+		dist.WithFailureThreshold(2),
+		dist.WithOpenTimeout(time.Millisecond*50),
+	)
+
+	for i := 0; i < 5; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	err = dist.Permanent(errors.New("invalid input"))
+	for i := 0; i < 5; i++ {
+		_, _ = cb.Execute(context.Background())
+	}
+	if cb.State() != dist.StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+	if counts := cb.Counts(); counts.TotalFailures != 0 {
+		t.Fatalf("expected no failures, got %+v", counts)
+	}
+
+	err = errors.New("upstream")
+	_, _ = cb.Execute(context.Background())
+	_, _ = cb.Execute(context.Background())
+	if cb.State() != dist.StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+
+	// an ignored probe frees its slot for the next one
+	clock.Advance(time.Millisecond * 60)
+	err = context.Canceled
+	_, _ = cb.Execute(context.Background())
+	err = errors.New("upstream")
+	_, e := cb.Execute(context.Background())
+	if errors.Is(e, dist.ErrCircuitOpen) {
+		t.Fatal("expected second probe to be let through")
+	}
+}
+
+func TestBreakerWithBackoff(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	circuit := func(ctx context.Context) (*string, error) {
+		return nil, errors.New("backoff")
+	}
+	cb := dist.NewCircuitBreaker[string](circuit,
+		dist.WithClock(clock),
+		dist.WithFailureThreshold(1),
+		dist.WithBackoff(dist.NewConstantBackoff(time.Millisecond*30)),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Execute(context.Background())
+		if cb.State() != dist.StateOpen {
+			t.Fatalf("expected open, got %s", cb.State())
+		}
+		clock.Advance(time.Millisecond * 40)
+		if cb.State() != dist.StateHalfOpen {
+			t.Fatalf("trip %d: expected half-open, got %s", i+1, cb.State())
+		}
+	}
+}
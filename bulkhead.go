@@ -0,0 +1,118 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrBulkheadFull is returned by a bulkhead that rejects a call because all
+// its slots are taken and its wait queue is full, or because the caller's
+// context ended while the call was queued.
+var ErrBulkheadFull = errors.New("bulkhead: full")
+
+type bulkheadConfig struct {
+	queueSize uint
+}
+
+// BulkheadOption configures a Bulkhead.
+type BulkheadOption interface {
+	applyBulkhead(*bulkheadConfig)
+}
+
+type bulkheadOptionFunc func(*bulkheadConfig)
+
+func (f bulkheadOptionFunc) applyBulkhead(c *bulkheadConfig) {
+	f(c)
+}
+
+// WithQueueSize lets up to n calls wait for a free slot when all slots are
+// taken, each for as long as its context allows. By default calls do not
+// wait and are rejected right away.
+func WithQueueSize(n uint) BulkheadOption {
+	return bulkheadOptionFunc(func(c *bulkheadConfig) {
+		c.queueSize = n
+	})
+}
+
+// Bulkhead wraps a circuit and caps how many calls to it may be in flight at
+// the same time, so that a slow dependency cannot tie up every goroutine of
+// its callers.
+type Bulkhead[T any] struct {
+	circuit   Circuit[T]
+	slots     chan struct{}
+	queueSize int64
+	queued    atomic.Int64
+}
+
+// NewBulkhead returns a bulkhead letting at most maxInFlight calls through to
+// circuit at the same time.
+func NewBulkhead[T any](circuit Circuit[T], maxInFlight uint, opts ...BulkheadOption) *Bulkhead[T] {
+	var cfg bulkheadConfig
+	for _, opt := range opts {
+		opt.applyBulkhead(&cfg)
+	}
+	if maxInFlight == 0 {
+		maxInFlight = 1
+	}
+
+	return &Bulkhead[T]{
+		circuit:   circuit,
+		slots:     make(chan struct{}, maxInFlight),
+		queueSize: int64(cfg.queueSize),
+	}
+}
+
+// Execute calls the wrapped circuit once it gets a free slot. It returns
+// ErrBulkheadFull if there is no free slot and no room in the wait queue.
+// A queued call that gives up because its context ended returns an error
+// matching both ErrBulkheadFull and the context error.
+func (b *Bulkhead[T]) Execute(ctx context.Context) (*T, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { <-b.slots }()
+
+	return b.circuit(ctx)
+}
+
+// Circuit returns the bulkhead as a circuit function.
+func (b *Bulkhead[T]) Circuit() Circuit[T] {
+	return b.Execute
+}
+
+// InFlight returns the number of calls currently running.
+func (b *Bulkhead[T]) InFlight() int {
+	return len(b.slots)
+}
+
+// Queued returns the number of calls currently waiting for a free slot.
+func (b *Bulkhead[T]) Queued() int {
+	return int(b.queued.Load())
+}
+
+func (b *Bulkhead[T]) acquire(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if b.queued.Add(1) > b.queueSize {
+		b.queued.Add(-1)
+		return ErrBulkheadFull
+	}
+	defer b.queued.Add(-1)
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrBulkheadFull, ctx.Err())
+	}
+}
@@ -0,0 +1,115 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+// blockingCircuit blocks every call until release is closed.
+type blockingCircuit struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingCircuit() *blockingCircuit {
+	return &blockingCircuit{
+		started: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (bc *blockingCircuit) call(ctx context.Context) (*string, error) {
+	bc.started <- struct{}{}
+	<-bc.release
+	result := "blockingCircuit"
+	return &result, nil
+}
+
+func TestBulkheadRejectsWhenFull(t *testing.T) {
+	bc := newBlockingCircuit()
+	b := dist.NewBulkhead[string](bc.call, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = b.Execute(context.Background())
+		}()
+		<-bc.started
+	}
+	if b.InFlight() != 2 {
+		t.Fatalf("expected 2 calls in flight, got %d", b.InFlight())
+	}
+
+	if _, err := b.Execute(context.Background()); !errors.Is(err, dist.ErrBulkheadFull) {
+		t.Fatalf("expected ErrBulkheadFull, got %v", err)
+	}
+
+	close(bc.release)
+	wg.Wait()
+	if b.InFlight() != 0 {
+		t.Fatalf("expected no calls in flight, got %d", b.InFlight())
+	}
+	if _, err := b.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	bc := newBlockingCircuit()
+	b := dist.NewBulkhead[string](bc.call, 1, dist.WithQueueSize(1))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = b.Execute(context.Background())
+	}()
+	<-bc.started
+	go func() {
+		defer wg.Done()
+		if _, err := b.Execute(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for b.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := b.Execute(context.Background()); !errors.Is(err, dist.ErrBulkheadFull) {
+		t.Fatalf("expected ErrBulkheadFull, got %v", err)
+	}
+
+	close(bc.release)
+	wg.Wait()
+	if b.Queued() != 0 {
+		t.Fatalf("expected empty queue, got %d", b.Queued())
+	}
+}
+
+func TestBulkheadQueueDeadline(t *testing.T) {
+	bc := newBlockingCircuit()
+	b := dist.NewBulkhead[string](bc.call, 1, dist.WithQueueSize(5))
+
+	go func() {
+		_, _ = b.Execute(context.Background())
+	}()
+	<-bc.started
+	defer close(bc.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err := b.Execute(ctx)
+	if !errors.Is(err, dist.ErrBulkheadFull) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrBulkheadFull and DeadlineExceeded, got %v", err)
+	}
+	if b.Queued() != 0 {
+		t.Fatalf("expected empty queue, got %d", b.Queued())
+	}
+}
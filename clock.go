@@ -0,0 +1,329 @@
+package dist
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for every time-dependent primitive in this
+// package. RealClock returns the system clock; FakeClock is a clock that
+// only moves when told to, for tests.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f once d has elapsed. The returned timer has no
+	// channel and can be used to cancel the call.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event created by a Clock, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// ClockOption sets the clock used by a primitive. It is accepted by every
+// constructor in this package that takes options.
+type ClockOption struct {
+	clock Clock
+}
+
+// WithClock makes a primitive read the time from c instead of the system
+// clock.
+func WithClock(c Clock) ClockOption {
+	return ClockOption{clock: c}
+}
+
+func (o ClockOption) applyBreaker(c *breakerConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyThrottle(c *throttleConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyDebounce(c *debounceConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyCache(c *cacheConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyAdaptive(c *adaptiveConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyRetry(c *retryConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyRetryBudget(c *retryBudgetConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyTimeout(c *timeoutConfig) {
+	c.clock = o.clock
+}
+
+func (o ClockOption) applyHedge(c *hedgeConfig) {
+	c.clock = o.clock
+}
+
+// RealClock returns the system clock.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+// withClockTimeout returns a copy of ctx ending with cause once d has elapsed
+// on clock. Go ends a context with a deadline on the wall clock, so the copy
+// only carries a deadline on the real clock; on any other clock a timer of
+// that clock ends it.
+func withClockTimeout(ctx context.Context, clock Clock, d time.Duration, cause error) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(realClock); ok {
+		return context.WithTimeoutCause(ctx, d, cause)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := clock.AfterFunc(d, func() {
+		cancel(cause)
+	})
+	return ctx, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock whose time only changes when Advance or Set is called.
+// Timers and tickers fire, in order, while the clock is moved past them;
+// functions passed to AfterFunc run on the goroutine moving the clock.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock returns a fake clock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	fc := &FakeClock{now: now}
+	fc.cond = sync.NewCond(&fc.mu)
+	return fc
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.now
+}
+
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	return fakeTimer{fc.add(&fakeWaiter{clock: fc, ch: make(chan time.Time, 1)}, d)}
+}
+
+func (fc *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("dist: non-positive interval for NewTicker")
+	}
+	return fakeTicker{fc.add(&fakeWaiter{clock: fc, ch: make(chan time.Time, 1), period: d}, d)}
+}
+
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return fakeTimer{fc.add(&fakeWaiter{clock: fc, fn: f}, d)}
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker that
+// falls due on the way.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	target := fc.now.Add(d)
+	fc.mu.Unlock()
+
+	fc.Set(target)
+}
+
+// Set moves the clock to t, firing every timer and ticker that falls due on
+// the way. Setting the clock back in time fires nothing.
+func (fc *FakeClock) Set(t time.Time) {
+	fc.mu.Lock()
+	for {
+		w := fc.next(t)
+		if w == nil {
+			break
+		}
+
+		fc.now = w.at
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			fc.remove(w)
+		}
+
+		if w.fn != nil {
+			fc.mu.Unlock()
+			w.fn()
+			fc.mu.Lock()
+			continue
+		}
+		select {
+		case w.ch <- fc.now:
+		default:
+		}
+	}
+	fc.now = t
+	fc.mu.Unlock()
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting on the
+// clock. Tests use it to make sure that a goroutine has started waiting
+// before they advance the clock.
+func (fc *FakeClock) BlockUntil(n int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for len(fc.waiters) < n {
+		fc.cond.Wait()
+	}
+}
+
+func (fc *FakeClock) add(w *fakeWaiter, d time.Duration) *fakeWaiter {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	w.at = fc.now.Add(d)
+	fc.waiters = append(fc.waiters, w)
+	fc.cond.Broadcast()
+	return w
+}
+
+// next returns the earliest waiter due at or before t.
+func (fc *FakeClock) next(t time.Time) *fakeWaiter {
+	sort.SliceStable(fc.waiters, func(i, j int) bool {
+		return fc.waiters[i].at.Before(fc.waiters[j].at)
+	})
+	if len(fc.waiters) == 0 || fc.waiters[0].at.After(t) {
+		return nil
+	}
+	return fc.waiters[0]
+}
+
+func (fc *FakeClock) remove(w *fakeWaiter) bool {
+	for i, other := range fc.waiters {
+		if other == w {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeWaiter is a timer, ticker or function waiting on a FakeClock.
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+func (w *fakeWaiter) stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	return w.clock.remove(w)
+}
+
+func (w *fakeWaiter) reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	active := w.clock.remove(w)
+	if w.period > 0 {
+		w.period = d
+	}
+	w.at = w.clock.now.Add(d)
+	w.clock.waiters = append(w.clock.waiters, w)
+	w.clock.cond.Broadcast()
+	return active
+}
+
+type fakeTimer struct {
+	w *fakeWaiter
+}
+
+func (t fakeTimer) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t fakeTimer) Stop() bool {
+	return t.w.stop()
+}
+
+func (t fakeTimer) Reset(d time.Duration) bool {
+	return t.w.reset(d)
+}
+
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t fakeTicker) Stop() {
+	t.w.stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("dist: non-positive interval for Ticker.Reset")
+	}
+	t.w.reset(d)
+}
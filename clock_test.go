@@ -0,0 +1,125 @@
+package dist_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := dist.NewFakeClock(start)
+
+	timer := clock.NewTimer(time.Second)
+	clock.Advance(time.Millisecond * 999)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(start.Add(time.Second)) {
+			t.Errorf("unexpected fire time %s", now)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+
+	if timer.Stop() {
+		t.Error("expected fired timer to be inactive")
+	}
+	if timer.Reset(time.Second) {
+		t.Error("expected fired timer to be inactive")
+	}
+	if !timer.Stop() {
+		t.Error("expected reset timer to be active")
+	}
+	clock.Advance(time.Second * 2)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var ticks int
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		select {
+		case <-ticker.C():
+			ticks++
+		default:
+		}
+	}
+	if ticks != 3 {
+		t.Errorf("expected 3 ticks, got %d", ticks)
+	}
+
+	// ticks that are not received are dropped, like with time.Ticker
+	clock.Advance(time.Second * 5)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatal("expected a single buffered tick")
+	default:
+	}
+}
+
+func TestFakeClockAfterFunc(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+
+	var order []int
+	clock.AfterFunc(time.Second*2, func() { order = append(order, 2) })
+	clock.AfterFunc(time.Second, func() { order = append(order, 1) })
+	stopped := clock.AfterFunc(time.Second, func() { order = append(order, 0) })
+	stopped.Stop()
+
+	clock.Advance(time.Second * 3)
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+
+	var fired atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		timer := clock.NewTimer(time.Minute)
+		<-timer.C()
+		fired.Store(true)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-done
+	if !fired.Load() {
+		t.Fatal("expected timer to fire")
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	clock := dist.RealClock()
+	before := time.Now()
+	if clock.Now().Before(before) {
+		t.Fatal("real clock is behind time.Now")
+	}
+
+	timer := clock.NewTimer(time.Millisecond)
+	<-timer.C()
+	ticker := clock.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+}
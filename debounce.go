@@ -6,6 +6,28 @@ import (
 	"time"
 )
 
+type debounceConfig struct {
+	clock Clock
+}
+
+// DebounceOption configures a debounced circuit.
+type DebounceOption interface {
+	applyDebounce(*debounceConfig)
+}
+
+func newDebounceConfig(opts []DebounceOption) debounceConfig {
+	cfg := debounceConfig{
+		clock: RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyDebounce(&cfg)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+	return cfg
+}
+
 // DebounceFirst returns a circuit that wraps the given circuit function and debounces its output.
 // The returned circuit waits for a duration of `d` before calling the wrapped circuit.
 // If the wrapped circuit is called again before the duration has elapsed, the previous result
@@ -14,14 +36,15 @@ import (
 // The function uses a mutex to protect the result and err variables, which are used to store the
 // last result and error from the wrapped circuit. The mutex is locked and unlocked around the
 // critical sections of the function to ensure that they are thread-safe.
-func DebounceFirst[T any](circuit Circuit[T], d time.Duration) Circuit[T] {
+func DebounceFirst[T any](circuit Circuit[T], d time.Duration, opts ...DebounceOption) Circuit[T] {
 	var threshold time.Time
 	var result *T
 	var err error
 	var mu sync.Mutex
+	cfg := newDebounceConfig(opts)
 
 	return func(ctx context.Context) (*T, error) {
-		if time.Now().Before(threshold) {
+		if cfg.clock.Now().Before(threshold) {
 			return result, err
 		}
 
@@ -29,7 +52,7 @@ func DebounceFirst[T any](circuit Circuit[T], d time.Duration) Circuit[T] {
 		defer mu.Unlock()
 
 		result, err = circuit(ctx)
-		threshold = time.Now().Add(d)
+		threshold = cfg.clock.Now().Add(d)
 
 		return result, err
 	}
@@ -47,21 +70,22 @@ func DebounceFirst[T any](circuit Circuit[T], d time.Duration) Circuit[T] {
 // The function uses a mutex to protect the result and err variables, which are used to store the
 // last result and error from the wrapped circuit. The mutex is locked and unlocked around the
 // critical sections of the function to ensure that they are thread-safe.
-func DebounceLast[T any](circuit Circuit[T], d time.Duration) Circuit[T] {
-	var ticker *time.Ticker
+func DebounceLast[T any](circuit Circuit[T], d time.Duration, opts ...DebounceOption) Circuit[T] {
+	var ticker Ticker
 	var result *T
 	var err error
 	var once sync.Once
 	var mu sync.Mutex
+	cfg := newDebounceConfig(opts)
 
 	return func(ctx context.Context) (*T, error) {
 		mu.Lock()
 		defer mu.Unlock()
 
-		threshold := time.Now().Add(d)
+		threshold := cfg.clock.Now().Add(d)
 
 		once.Do(func() {
-			ticker = time.NewTicker(time.Millisecond * 100)
+			ticker = cfg.clock.NewTicker(time.Millisecond * 100)
 
 			go func() {
 				defer func() {
@@ -72,8 +96,8 @@ func DebounceLast[T any](circuit Circuit[T], d time.Duration) Circuit[T] {
 				}()
 				for {
 					select {
-					case <-ticker.C:
-						if time.Now().After(threshold) {
+					case <-ticker.C():
+						if cfg.clock.Now().After(threshold) {
 							mu.Lock()
 							result, err = circuit(ctx)
 							mu.Unlock()
@@ -59,3 +59,27 @@ func TestDebounceLast(t *testing.T) {
 	}
 	mockCircuitCalled = 0
 }
+
+func TestDebounceFirstWithClock(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var calls int64
+	circuit := dist.DebounceFirst[string](func(ctx context.Context) (*string, error) {
+		calls++
+		result := "debounce called"
+		return &result, nil
+	}, time.Second, dist.WithClock(clock))
+
+	for i := 0; i < 10; i++ {
+		_, _ = circuit(context.Background())
+		clock.Advance(time.Millisecond * 50)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+
+	clock.Advance(time.Second)
+	_, _ = circuit(context.Background())
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
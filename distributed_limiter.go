@@ -0,0 +1,252 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultStoreTimeout = time.Millisecond * 100
+
+// RateLimitRule describes the token bucket a RateLimitStore keeps for a key.
+type RateLimitRule struct {
+	MaxTokens      uint
+	RefillTokens   uint
+	RefillDuration time.Duration
+}
+
+// RateLimitResult is the outcome of taking tokens from a RateLimitStore.
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+	// RetryAfter estimates, for a call that is not allowed, how long until
+	// it would be.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets by key for limiters in several
+// processes, so that a limit holds across all of them.
+type RateLimitStore interface {
+	// TakeN refills the bucket of key as rule says and takes n tokens from
+	// it if there are that many, as a single atomic step. A key seen for the
+	// first time starts with a full bucket.
+	TakeN(ctx context.Context, key string, rule RateLimitRule, n uint) (RateLimitResult, error)
+}
+
+// StoreTimeoutOption bounds how long a primitive waits for its shared store.
+// It is accepted by circuit breakers and distributed limiters.
+type StoreTimeoutOption struct {
+	timeout time.Duration
+}
+
+// WithStoreTimeout bounds how long a primitive waits for its store before
+// carrying on without it: a breaker with its local state, a
+// DistributedLimiter with its local limiter. A breaker gets d for loading its
+// record before a call and d again for updating it after the call. It
+// defaults to 100ms.
+func WithStoreTimeout(d time.Duration) StoreTimeoutOption {
+	return StoreTimeoutOption{timeout: d}
+}
+
+func (o StoreTimeoutOption) applyBreaker(c *breakerConfig) {
+	c.storeTimeout = o.timeout
+}
+
+func (o StoreTimeoutOption) applyThrottle(c *throttleConfig) {
+	c.storeTimeout = o.timeout
+}
+
+// WithFallbackLimiter sets the limiter a DistributedLimiter uses while its
+// store is unreachable. By default it falls back to a KeyedLimiter with the
+// same rule, which then holds per process rather than across processes;
+// pass a stricter limiter to keep the combined rate of all processes down.
+func WithFallbackLimiter(kl *KeyedLimiter) ThrottleOption {
+	return throttleOptionFunc(func(c *throttleConfig) {
+		c.fallback = kl
+	})
+}
+
+// DistributedLimiter is a keyed rate limiter whose buckets live in a
+// RateLimitStore shared by all replicas of a process. When the store fails or
+// does not answer in time, the limiter carries on with a local KeyedLimiter.
+type DistributedLimiter struct {
+	store   RateLimitStore
+	rule    RateLimitRule
+	timeout time.Duration
+	local   *KeyedLimiter
+}
+
+// NewDistributedLimiter returns a limiter giving every key a bucket in store
+// holding up to maxTokens tokens and refilled with refillTokens tokens every
+// refillDuration.
+func NewDistributedLimiter(store RateLimitStore, maxTokens uint, refillTokens uint, refillDuration time.Duration, opts ...ThrottleOption) *DistributedLimiter {
+	cfg := newThrottleConfig(opts)
+	if cfg.storeTimeout <= 0 {
+		cfg.storeTimeout = defaultStoreTimeout
+	}
+	if cfg.fallback == nil {
+		cfg.fallback = NewKeyedLimiter(maxTokens, refillTokens, refillDuration, opts...)
+	}
+
+	return &DistributedLimiter{
+		store: store,
+		rule: RateLimitRule{
+			MaxTokens:      maxTokens,
+			RefillTokens:   refillTokens,
+			RefillDuration: refillDuration,
+		},
+		timeout: cfg.storeTimeout,
+		local:   cfg.fallback,
+	}
+}
+
+// Allow takes a token from the bucket of key if there is one and reports
+// whether it did.
+func (dl *DistributedLimiter) Allow(ctx context.Context, key string) bool {
+	return dl.TakeN(ctx, key, 1) == nil
+}
+
+// TakeN takes n tokens from the bucket of key if there are that many, or
+// returns a *ThrottleError telling when to try again. It only returns another
+// error if ctx ends.
+func (dl *DistributedLimiter) TakeN(ctx context.Context, key string, n uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storeCtx, cancel := context.WithTimeout(ctx, dl.timeout)
+	res, err := dl.store.TakeN(storeCtx, key, dl.rule, n)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return dl.local.TakeN(key, n)
+	}
+
+	if !res.Allowed {
+		return &ThrottleError{
+			RetryAfter: res.RetryAfter,
+			Remaining:  res.Remaining,
+			Limit:      int(dl.rule.MaxTokens),
+		}
+	}
+	return nil
+}
+
+// ThrottleDistributed wraps an effector with a DistributedLimiter, like
+// ThrottleByKey. Calls are rejected with a *ThrottleError.
+func ThrottleDistributed[T any](e Effector[T], dl *DistributedLimiter, key func(context.Context) string) Effector[T] {
+	key = limitKeyFunc(key)
+
+	return func(ctx context.Context) (*T, error) {
+		if err := dl.TakeN(ctx, key(ctx), 1); err != nil {
+			return nil, err
+		}
+		return e(ctx)
+	}
+}
+
+// MemoryRateLimitStore is a RateLimitStore keeping its buckets in memory,
+// dropping the buckets of idle keys like a KeyedLimiter. It shares limits
+// between limiters of the same process, or between processes when served
+// with ServeRateLimitStore.
+type MemoryRateLimitStore struct {
+	opts []ThrottleOption
+
+	mu       sync.Mutex
+	limiters map[RateLimitRule]*KeyedLimiter
+}
+
+// NewMemoryRateLimitStore returns an empty store. The options are passed to
+// the KeyedLimiter holding the buckets of every rule.
+func NewMemoryRateLimitStore(opts ...ThrottleOption) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		opts:     opts,
+		limiters: make(map[RateLimitRule]*KeyedLimiter),
+	}
+}
+
+func (s *MemoryRateLimitStore) TakeN(_ context.Context, key string, rule RateLimitRule, n uint) (RateLimitResult, error) {
+	kl := s.limiter(rule)
+
+	err := kl.TakeN(key, n)
+	var te *ThrottleError
+	if errors.As(err, &te) {
+		return RateLimitResult{
+			Remaining:  te.Remaining,
+			RetryAfter: te.RetryAfter,
+		}, nil
+	}
+	return RateLimitResult{
+		Allowed:   true,
+		Remaining: max(kl.Tokens(key), 0),
+	}, nil
+}
+
+func (s *MemoryRateLimitStore) limiter(rule RateLimitRule) *KeyedLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kl, ok := s.limiters[rule]
+	if !ok {
+		kl = NewKeyedLimiter(rule.MaxTokens, rule.RefillTokens, rule.RefillDuration, s.opts...)
+		s.limiters[rule] = kl
+	}
+	return kl
+}
+
+// rateLimitTake is an alias of an unnamed struct because net/rpc only accepts
+// exported or unnamed argument types.
+type rateLimitTake = struct {
+	Key  string
+	Rule RateLimitRule
+	N    uint
+}
+
+// rateLimitStoreService exposes a RateLimitStore over net/rpc.
+type rateLimitStoreService struct {
+	store RateLimitStore
+}
+
+func (s *rateLimitStoreService) TakeN(args rateLimitTake, res *RateLimitResult) error {
+	var err error
+	*res, err = s.store.TakeN(context.Background(), args.Key, args.Rule, args.N)
+	return err
+}
+
+// ServeRateLimitStore serves store to RemoteRateLimitStore clients connecting
+// to l. It blocks until l stops accepting connections and returns the error
+// that stopped it.
+func ServeRateLimitStore(l net.Listener, store RateLimitStore) error {
+	return serveRPC(l, "RateLimitStore", &rateLimitStoreService{store: store})
+}
+
+// RemoteRateLimitStore is a RateLimitStore served by ServeRateLimitStore in
+// another process. It connects on first use and reconnects after the
+// connection breaks.
+type RemoteRateLimitStore struct {
+	client *rpcClient
+}
+
+// NewRemoteRateLimitStore returns a store talking to the server listening on
+// the given network address, for example "tcp" and "127.0.0.1:7071".
+func NewRemoteRateLimitStore(network string, addr string) *RemoteRateLimitStore {
+	return &RemoteRateLimitStore{
+		client: newRPCClient(network, addr),
+	}
+}
+
+func (s *RemoteRateLimitStore) TakeN(ctx context.Context, key string, rule RateLimitRule, n uint) (RateLimitResult, error) {
+	var res RateLimitResult
+	err := s.client.call(ctx, "RateLimitStore.TakeN", rateLimitTake{Key: key, Rule: rule, N: n}, &res)
+	return res, err
+}
+
+// Close closes the connection to the server.
+func (s *RemoteRateLimitStore) Close() error {
+	return s.client.close()
+}
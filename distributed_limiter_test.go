@@ -0,0 +1,196 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestDistributedLimiterSharesQuota(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	store := dist.NewMemoryRateLimitStore(dist.WithClock(clock))
+	a := dist.NewDistributedLimiter(store, 4, 1, time.Second, dist.WithClock(clock))
+	b := dist.NewDistributedLimiter(store, 4, 1, time.Second, dist.WithClock(clock))
+
+	for i := 0; i < 2; i++ {
+		if !a.Allow(context.Background(), "alice") || !b.Allow(context.Background(), "alice") {
+			t.Fatalf("expected call %d to be allowed on both replicas", i)
+		}
+	}
+
+	err := b.TakeN(context.Background(), "alice", 1)
+	var te *dist.ThrottleError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected the quota to hold across replicas, got %v", err)
+	}
+	if te.RetryAfter != time.Second || te.Remaining != 0 || te.Limit != 4 {
+		t.Fatalf("unexpected throttle error %+v", te)
+	}
+	if !a.Allow(context.Background(), "bob") {
+		t.Fatal("expected bob to have a quota of his own")
+	}
+
+	clock.Advance(time.Second)
+	if !a.Allow(context.Background(), "alice") {
+		t.Fatal("expected alice to be refilled")
+	}
+}
+
+func TestRemoteRateLimitStore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- dist.ServeRateLimitStore(l, dist.NewMemoryRateLimitStore())
+	}()
+
+	remoteA := dist.NewRemoteRateLimitStore("tcp", l.Addr().String())
+	defer remoteA.Close()
+	remoteB := dist.NewRemoteRateLimitStore("tcp", l.Addr().String())
+	defer remoteB.Close()
+
+	a := dist.NewDistributedLimiter(remoteA, 3, 1, time.Minute, dist.WithStoreTimeout(time.Second))
+	b := dist.NewDistributedLimiter(remoteB, 3, 1, time.Minute, dist.WithStoreTimeout(time.Second))
+
+	var calls int
+	effector := func(context.Context) (*string, error) {
+		calls++
+		res := "ok"
+		return &res, nil
+	}
+	throttledA := dist.ThrottleDistributed(effector, a, nil)
+	throttledB := dist.ThrottleDistributed(effector, b, nil)
+
+	ctx := dist.WithLimitKey(context.Background(), "alice")
+	for _, throttled := range []dist.Effector[string]{throttledA, throttledB, throttledA} {
+		if _, err := throttled(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := throttledB(ctx); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	res, err := remoteA.TakeN(context.Background(), "bob", dist.RateLimitRule{MaxTokens: 3, RefillTokens: 1, RefillDuration: time.Minute}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	_ = l.Close()
+	if err := <-done; err == nil {
+		t.Fatal("expected server to stop with an error")
+	}
+}
+
+func TestDistributedLimiterFallback(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	remote := dist.NewRemoteRateLimitStore("tcp", addr)
+	defer remote.Close()
+
+	dl := dist.NewDistributedLimiter(remote, 2, 1, time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := dl.TakeN(context.Background(), "alice", 1); err != nil {
+			t.Fatalf("expected the local fallback to allow call %d, got %v", i, err)
+		}
+	}
+	if err := dl.TakeN(context.Background(), "alice", 1); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected the local fallback to throttle, got %v", err)
+	}
+
+	fallback := dist.NewKeyedLimiter(1, 1, time.Minute)
+	dl = dist.NewDistributedLimiter(remote, 2, 1, time.Minute, dist.WithFallbackLimiter(fallback))
+	_ = dl.TakeN(context.Background(), "alice", 1)
+	if err := dl.TakeN(context.Background(), "alice", 1); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected the given fallback to throttle, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := dl.TakeN(ctx, "bob", 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+type slowRateLimitStore struct{}
+
+func (slowRateLimitStore) TakeN(ctx context.Context, _ string, _ dist.RateLimitRule, _ uint) (dist.RateLimitResult, error) {
+	<-ctx.Done()
+	return dist.RateLimitResult{}, ctx.Err()
+}
+
+func TestDistributedLimiterStoreTimeout(t *testing.T) {
+	dl := dist.NewDistributedLimiter(slowRateLimitStore{}, 1, 1, time.Minute, dist.WithStoreTimeout(time.Millisecond*10))
+
+	start := time.Now()
+	if err := dl.TakeN(context.Background(), "alice", 1); err != nil {
+		t.Fatalf("expected the local fallback to allow the call, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("expected the store call to time out")
+	}
+	if err := dl.TakeN(context.Background(), "alice", 1); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected the local fallback to throttle, got %v", err)
+	}
+}
+
+// sleepyRateLimitStore answers its first call late, allowing it, and
+// rejects every later call right away.
+type sleepyRateLimitStore struct {
+	delay time.Duration
+	calls atomic.Int64
+}
+
+func (s *sleepyRateLimitStore) TakeN(context.Context, string, dist.RateLimitRule, uint) (dist.RateLimitResult, error) {
+	if s.calls.Add(1) == 1 {
+		time.Sleep(s.delay)
+		return dist.RateLimitResult{Allowed: true, Remaining: 1}, nil
+	}
+	return dist.RateLimitResult{RetryAfter: time.Second * 42}, nil
+}
+
+func TestRemoteRateLimitStoreLateReply(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		_ = dist.ServeRateLimitStore(l, &sleepyRateLimitStore{delay: time.Millisecond * 30})
+	}()
+
+	remote := dist.NewRemoteRateLimitStore("tcp", l.Addr().String())
+	defer remote.Close()
+	dl := dist.NewDistributedLimiter(remote, 1, 1, time.Minute, dist.WithStoreTimeout(time.Millisecond*10))
+
+	if err := dl.TakeN(context.Background(), "alice", 1); err != nil {
+		t.Fatalf("expected the local fallback to allow the call, got %v", err)
+	}
+
+	// Once the late reply has arrived, the next call gets the answer of the
+	// store to that call, not the late reply or the local fallback.
+	time.Sleep(time.Millisecond * 40)
+	err = dl.TakeN(context.Background(), "alice", 1)
+	var te *dist.ThrottleError
+	if !errors.As(err, &te) || te.RetryAfter != time.Second*42 {
+		t.Fatalf("expected the store to reject the call, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package dist
+
+import (
+	"context"
+	"errors"
+)
+
+// classifiedError marks the error it wraps as either permanent or transient.
+type classifiedError struct {
+	err       error
+	permanent bool
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err to mark it as permanent: the call failed for a reason
+// that says nothing about the health of the dependency, such as invalid
+// input, and repeating it will not help. Permanent errors are not counted as
+// failures. Permanent returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, permanent: true}
+}
+
+// Transient wraps err to mark it as transient: the dependency is unhealthy
+// and the call may succeed if repeated later. Transient errors are always
+// counted as failures. Transient returns nil if err is nil.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, permanent: false}
+}
+
+// IsPermanent reports whether err has been marked as permanent. When err is
+// marked more than once, the outermost mark wins.
+func IsPermanent(err error) bool {
+	var ce *classifiedError
+	return errors.As(err, &ce) && ce.permanent
+}
+
+// IsTransient reports whether err has been marked as transient. When err is
+// marked more than once, the outermost mark wins.
+func IsTransient(err error) bool {
+	var ce *classifiedError
+	return errors.As(err, &ce) && !ce.permanent
+}
+
+// IsFailure is the default predicate used by the wrappers in this package to
+// decide whether an error returned from a circuit counts against the health of
+// the dependency. Transient errors are failures, permanent errors are not, and
+// neither is context.Canceled, which only means the caller gave up. Any other
+// non-nil error is a failure.
+func IsFailure(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case IsTransient(err):
+		return true
+	case IsPermanent(err):
+		return false
+	case errors.Is(err, context.Canceled):
+		return false
+	default:
+		return true
+	}
+}
+
+// FailureOption sets the predicate used by a primitive to classify errors. It
+// is accepted by every constructor in this package that tells failures apart.
+type FailureOption struct {
+	isFailure func(error) bool
+}
+
+// WithIsFailure sets the predicate that decides whether an error returned from
+// the circuit counts as a failure. Errors that are not failures are ignored:
+// they count neither as successes nor as failures. The default is IsFailure.
+func WithIsFailure(fn func(error) bool) FailureOption {
+	return FailureOption{isFailure: fn}
+}
+
+func (o FailureOption) applyBreaker(c *breakerConfig) {
+	c.isFailure = o.isFailure
+}
+
+func (o FailureOption) applyAdaptive(c *adaptiveConfig) {
+	c.isFailure = o.isFailure
+}
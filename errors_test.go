@@ -0,0 +1,60 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestIsFailure(t *testing.T) {
+	errUpstream := errors.New("upstream")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", errUpstream, true},
+		{"canceled", context.Canceled, false},
+		{"wrapped canceled", fmt.Errorf("call: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"permanent", dist.Permanent(errUpstream), false},
+		{"wrapped permanent", fmt.Errorf("call: %w", dist.Permanent(errUpstream)), false},
+		{"transient", dist.Transient(errUpstream), true},
+		{"transient canceled", dist.Transient(context.Canceled), true},
+		{"transient over permanent", dist.Transient(dist.Permanent(errUpstream)), true},
+	}
+	for _, tt := range tests {
+		if got := dist.IsFailure(tt.err); got != tt.want {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPermanentAndTransient(t *testing.T) {
+	errUpstream := errors.New("upstream")
+
+	if dist.Permanent(nil) != nil || dist.Transient(nil) != nil {
+		t.Fatal("expected nil errors to stay nil")
+	}
+
+	err := dist.Permanent(errUpstream)
+	if !errors.Is(err, errUpstream) {
+		t.Error("expected permanent error to unwrap")
+	}
+	if err.Error() != errUpstream.Error() {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if !dist.IsPermanent(err) || dist.IsTransient(err) {
+		t.Error("expected permanent error")
+	}
+
+	err = dist.Transient(err)
+	if dist.IsPermanent(err) || !dist.IsTransient(err) {
+		t.Error("expected transient error")
+	}
+}
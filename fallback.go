@@ -0,0 +1,128 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNotCached is returned by a fallback from a cache holding no value for
+// the key of the call.
+var ErrNotCached = errors.New("fallback: no cached value")
+
+// FallbackResult is the result of a call made through Fallback.
+type FallbackResult[T any] struct {
+	Value *T
+	// Degraded is true if Value comes from the fallback rather than from
+	// the circuit.
+	Degraded bool
+	// Cause is the error the circuit failed with when Degraded is true.
+	Cause error
+}
+
+// FallbackFunc returns an alternative result for a call that failed with
+// cause.
+type FallbackFunc[T any] func(ctx context.Context, cause error) (*T, error)
+
+type fallbackConfig struct {
+	fallbackIf func(error) bool
+}
+
+// FallbackOption configures a fallback.
+type FallbackOption interface {
+	applyFallback(*fallbackConfig)
+}
+
+type fallbackOptionFunc func(*fallbackConfig)
+
+func (f fallbackOptionFunc) applyFallback(c *fallbackConfig) {
+	f(c)
+}
+
+// WithFallbackIf sets the predicate that decides whether a call that failed
+// with an error falls back. The default is IsFailure, so that permanent
+// errors and canceled calls are returned as they are.
+func WithFallbackIf(fn func(error) bool) FallbackOption {
+	return fallbackOptionFunc(func(c *fallbackConfig) {
+		c.fallbackIf = fn
+	})
+}
+
+// Fallback wraps a circuit so that calls failing with an error the predicate
+// matches, such as ErrCircuitOpen, are answered by fallback instead. The
+// result tells whether it was degraded and why. If fallback fails too, the
+// error returned wraps both errors.
+func Fallback[T any](circuit Circuit[T], fallback FallbackFunc[T], opts ...FallbackOption) Circuit[FallbackResult[T]] {
+	cfg := fallbackConfig{
+		fallbackIf: IsFailure,
+	}
+	for _, opt := range opts {
+		opt.applyFallback(&cfg)
+	}
+	if cfg.fallbackIf == nil {
+		cfg.fallbackIf = IsFailure
+	}
+
+	return func(ctx context.Context) (*FallbackResult[T], error) {
+		res, err := circuit(ctx)
+		if err == nil {
+			return &FallbackResult[T]{Value: res}, nil
+		}
+		if !cfg.fallbackIf(err) {
+			return nil, err
+		}
+
+		res, fbErr := fallback(ctx, err)
+		if fbErr != nil {
+			return nil, fmt.Errorf("%w; fallback: %w", err, fbErr)
+		}
+		return &FallbackResult[T]{
+			Value:    res,
+			Degraded: true,
+			Cause:    err,
+		}, nil
+	}
+}
+
+// FallbackValue returns a fallback always answering a copy of v.
+func FallbackValue[T any](v T) FallbackFunc[T] {
+	return func(context.Context, error) (*T, error) {
+		v := v
+		return &v, nil
+	}
+}
+
+// FallbackCircuit returns a fallback calling another circuit, such as a
+// secondary backend.
+func FallbackCircuit[T any](circuit Circuit[T]) FallbackFunc[T] {
+	return func(ctx context.Context, _ error) (*T, error) {
+		return circuit(ctx)
+	}
+}
+
+// FallbackFromCache returns a circuit storing every result of circuit in
+// cache under the key of the call, and a fallback answering with the value
+// last stored under that key, however old. The fallback fails with
+// ErrNotCached when there is no such value. Values are stored without an
+// expiry, so only the capacity of the cache drops them.
+func FallbackFromCache[K comparable, T any](circuit Circuit[T], cache *LRUCache[K, T], key func(context.Context) K) (Circuit[T], FallbackFunc[T]) {
+	cached := func(ctx context.Context) (*T, error) {
+		res, err := circuit(ctx)
+		if err == nil && res != nil {
+			cache.Add(key(ctx), *res, 0, math.MaxInt64)
+		}
+		return res, err
+	}
+
+	fallback := func(ctx context.Context, _ error) (*T, error) {
+		item, ok := cache.Get(key(ctx))
+		if !ok {
+			return nil, ErrNotCached
+		}
+		v := item.Value
+		return &v, nil
+	}
+
+	return cached, fallback
+}
@@ -0,0 +1,102 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestFallbackValue(t *testing.T) {
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	breaker := dist.Breaker[string](failing.call, 1, dist.WithOpenTimeout(time.Minute))
+	circuit := dist.Fallback(breaker, dist.FallbackValue("default"))
+
+	for i := 0; i < 2; i++ {
+		res, err := circuit(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !res.Degraded || *res.Value != "default" {
+			t.Fatalf("expected a degraded default, got %+v", res)
+		}
+		// callers own the value they get back
+		*res.Value = "changed"
+	}
+	res, _ := circuit(context.Background())
+	if !errors.Is(res.Cause, dist.ErrCircuitOpen) {
+		t.Fatalf("expected the open circuit as the cause, got %v", res.Cause)
+	}
+
+	failing.fail.Store(false)
+	healthy := dist.Fallback(failing.call, dist.FallbackValue("default"))
+	res, err := healthy(context.Background())
+	if err != nil || res.Degraded || res.Cause != nil {
+		t.Fatalf("expected a result from the circuit, got %+v, %v", res, err)
+	}
+}
+
+func TestFallbackIf(t *testing.T) {
+	permanent := dist.Permanent(errUnavailable)
+	circuit := dist.Fallback(func(context.Context) (*string, error) {
+		return nil, permanent
+	}, dist.FallbackValue("default"))
+
+	if _, err := circuit(context.Background()); !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected a permanent error to be returned as is, got %v", err)
+	}
+
+	circuit = dist.Fallback(func(context.Context) (*string, error) {
+		return nil, permanent
+	}, dist.FallbackValue("default"), dist.WithFallbackIf(func(error) bool { return true }))
+	if res, err := circuit(context.Background()); err != nil || !res.Degraded {
+		t.Fatalf("expected a degraded result, got %+v, %v", res, err)
+	}
+}
+
+func TestFallbackCircuit(t *testing.T) {
+	errSecondary := errors.New("secondary down")
+	primary := func(context.Context) (*string, error) {
+		return nil, errUnavailable
+	}
+	secondary := func(context.Context) (*string, error) {
+		return nil, errSecondary
+	}
+
+	circuit := dist.Fallback(primary, dist.FallbackCircuit[string](secondary))
+	_, err := circuit(context.Background())
+	if !errors.Is(err, errUnavailable) || !errors.Is(err, errSecondary) {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+}
+
+func TestFallbackFromCache(t *testing.T) {
+	cache := dist.NewLRUCache[string, string](10)
+	key := func(ctx context.Context) string {
+		k, _ := dist.LimitKeyFromContext(ctx)
+		return k
+	}
+	flaky := &flakyCircuit{}
+	primary, stale := dist.FallbackFromCache(flaky.call, cache, key)
+	circuit := dist.Fallback(primary, stale)
+
+	alice := dist.WithLimitKey(context.Background(), "alice")
+	bob := dist.WithLimitKey(context.Background(), "bob")
+
+	fresh, err := circuit(alice)
+	if err != nil || fresh.Degraded {
+		t.Fatalf("unexpected result %+v, %v", fresh, err)
+	}
+
+	flaky.fail.Store(true)
+	res, err := circuit(alice)
+	if err != nil || !res.Degraded || *res.Value != *fresh.Value {
+		t.Fatalf("expected the stale value %q, got %+v, %v", *fresh.Value, res, err)
+	}
+	if _, err := circuit(bob); !errors.Is(err, dist.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package dist
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Func is a function taking an argument, such as a user ID, and returning a
+// value rather than a pointer. The wrappers for Func keep a single state,
+// such as one breaker, for calls with every argument, unlike wrapping a new
+// Circuit closure for every argument.
+type Func[In any, Out any] func(context.Context, In) (Out, error)
+
+// funcArg is the context key ApplyFunc passes the argument of a call under.
+type funcArg[In any] struct{}
+
+// ApplyFunc wraps fn with a middleware made for circuits. The middleware is
+// applied once, and the argument of every call is passed to fn through the
+// context of the call, so that whatever state the middleware keeps is shared
+// by calls with all arguments. A middleware returning a nil result without an
+// error makes the call return the zero value.
+func ApplyFunc[In any, Out any](fn Func[In, Out], mw Middleware[Out]) Func[In, Out] {
+	circuit := mw(func(ctx context.Context) (*Out, error) {
+		in, _ := ctx.Value(funcArg[In]{}).(In)
+		out, err := fn(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return &out, nil
+	})
+
+	return func(ctx context.Context, in In) (Out, error) {
+		var out Out
+		res, err := circuit(context.WithValue(ctx, funcArg[In]{}, in))
+		if res != nil {
+			out = *res
+		}
+		return out, err
+	}
+}
+
+// BreakerFunc guards fn with a single circuit breaker, as NewCircuitBreaker
+// does, shared by calls with every argument.
+func BreakerFunc[In any, Out any](fn Func[In, Out], opts ...BreakerOption) Func[In, Out] {
+	return ApplyFunc(fn, func(c Circuit[Out]) Circuit[Out] {
+		return NewCircuitBreaker(c, opts...).Execute
+	})
+}
+
+// ThrottleFunc throttles fn with a single token bucket, as ThrottleWithRefill
+// does, shared by calls with every argument.
+func ThrottleFunc[In any, Out any](fn Func[In, Out], maxTokens uint, refillTokens uint, refillDuration time.Duration, opts ...ThrottleOption) Func[In, Out] {
+	return ApplyFunc(fn, func(c Circuit[Out]) Circuit[Out] {
+		return ThrottleWithRefill(c, maxTokens, refillTokens, refillDuration, opts...)
+	})
+}
+
+// RetryFunc retries calls to fn as Retry does. A retry budget passed in the
+// options is shared by calls with every argument.
+func RetryFunc[In any, Out any](fn Func[In, Out], opts ...RetryOption) Func[In, Out] {
+	return ApplyFunc(fn, func(c Circuit[Out]) Circuit[Out] {
+		return Retry(c, opts...)
+	})
+}
+
+// DebounceFunc debounces calls to fn as DebounceFirst does, separately for
+// every argument: a call returns the result of the previous call with the
+// same argument if it was made less than d ago. Results are kept only while
+// their debounce lasts; the arguments not called for d are dropped on a later
+// call, at most once per d, so memory stays bounded by the arguments in use.
+func DebounceFunc[In comparable, Out any](fn Func[In, Out], d time.Duration, opts ...DebounceOption) Func[In, Out] {
+	cfg := newDebounceConfig(opts)
+	var mu sync.Mutex
+	debounced := make(map[In]*debounceEntry[Out])
+	lastSweep := cfg.clock.Now()
+
+	return func(ctx context.Context, in In) (Out, error) {
+		mu.Lock()
+		now := cfg.clock.Now()
+		if now.Sub(lastSweep) >= d {
+			for k, e := range debounced {
+				if e.active == 0 && now.Sub(e.lastUsed) >= d {
+					delete(debounced, k)
+				}
+			}
+			lastSweep = now
+		}
+		entry, ok := debounced[in]
+		if !ok {
+			entry = &debounceEntry[Out]{
+				circuit: DebounceFirst(func(ctx context.Context) (*Out, error) {
+					out, err := fn(ctx, in)
+					return &out, err
+				}, d, opts...),
+			}
+			debounced[in] = entry
+		}
+		entry.active++
+		mu.Unlock()
+
+		var out Out
+		res, err := entry.circuit(ctx)
+		if res != nil {
+			out = *res
+		}
+
+		mu.Lock()
+		entry.active--
+		entry.lastUsed = cfg.clock.Now()
+		mu.Unlock()
+		return out, err
+	}
+}
+
+// debounceEntry is the debounced circuit DebounceFunc keeps for an argument.
+type debounceEntry[Out any] struct {
+	circuit  Circuit[Out]
+	active   int
+	lastUsed time.Time
+}
+
+// CacheFunc caches the results of fn in cache by argument for ttl. Calls with
+// an argument whose result is cached and has not expired return it without
+// calling fn. Errors are not cached.
+func CacheFunc[In comparable, Out any](fn Func[In, Out], cache *LRUCache[In, Out], ttl time.Duration) Func[In, Out] {
+	return func(ctx context.Context, in In) (Out, error) {
+		if item, ok := cache.Get(in); ok && item.Expiry >= cache.clock.Now().Unix() {
+			return item.Value, nil
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			return out, err
+		}
+		cache.Add(in, out, 0, cache.clock.Now().Add(ttl).Unix())
+		return out, nil
+	}
+}
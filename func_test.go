@@ -0,0 +1,182 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+// userService answers GetUser and counts the calls made for every user.
+type userService struct {
+	calls map[int]int
+	fail  bool
+}
+
+func (s *userService) GetUser(_ context.Context, id int) (user, error) {
+	if s.calls == nil {
+		s.calls = make(map[int]int)
+	}
+	s.calls[id]++
+	if s.fail {
+		return user{}, errUnavailable
+	}
+	return user{ID: id, Name: fmt.Sprintf("user-%d", id)}, nil
+}
+
+func TestApplyFunc(t *testing.T) {
+	svc := &userService{}
+	var trace []string
+	getUser := dist.ApplyFunc(svc.GetUser, countCalls(&trace))
+
+	u, err := getUser(context.Background(), 7)
+	if err != nil || u.Name != "user-7" {
+		t.Fatalf("unexpected result %+v, %v", u, err)
+	}
+	if len(trace) != 1 {
+		t.Fatalf("expected the middleware to run once, got %v", trace)
+	}
+
+	svc.fail = true
+	u, err = getUser(context.Background(), 8)
+	if !errors.Is(err, errUnavailable) || u != (user{}) {
+		t.Fatalf("expected the zero value and the error, got %+v, %v", u, err)
+	}
+}
+
+func countCalls(trace *[]string) dist.Middleware[user] {
+	return func(c dist.Circuit[user]) dist.Circuit[user] {
+		return func(ctx context.Context) (*user, error) {
+			*trace = append(*trace, "call")
+			return c(ctx)
+		}
+	}
+}
+
+func TestBreakerFuncSharesState(t *testing.T) {
+	svc := &userService{fail: true}
+	getUser := dist.BreakerFunc(svc.GetUser, dist.WithFailureThreshold(2), dist.WithOpenTimeout(time.Minute))
+
+	_, _ = getUser(context.Background(), 1)
+	_, _ = getUser(context.Background(), 2)
+	if _, err := getUser(context.Background(), 3); !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected failures with different arguments to open the breaker, got %v", err)
+	}
+	if svc.calls[3] != 0 {
+		t.Fatal("expected the open breaker to stop the call")
+	}
+}
+
+func TestThrottleFunc(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	svc := &userService{}
+	getUser := dist.ThrottleFunc(svc.GetUser, 2, 1, time.Second, dist.WithClock(clock))
+
+	for id := 1; id <= 2; id++ {
+		if _, err := getUser(context.Background(), id); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := getUser(context.Background(), 3); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected calls with every argument to share the bucket, got %v", err)
+	}
+}
+
+func TestRetryFunc(t *testing.T) {
+	failures := 2
+	getUser := dist.RetryFunc(func(_ context.Context, id int) (user, error) {
+		if failures > 0 {
+			failures--
+			return user{}, errUnavailable
+		}
+		return user{ID: id}, nil
+	}, dist.WithBackoff(dist.NewConstantBackoff(0)))
+
+	u, err := getUser(context.Background(), 5)
+	if err != nil || u.ID != 5 {
+		t.Fatalf("unexpected result %+v, %v", u, err)
+	}
+}
+
+func TestDebounceFunc(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	svc := &userService{}
+	getUser := dist.DebounceFunc(svc.GetUser, time.Second, dist.WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		_, _ = getUser(context.Background(), 1)
+	}
+	u, err := getUser(context.Background(), 2)
+	if err != nil || u.ID != 2 {
+		t.Fatalf("expected user 2, got %+v, %v", u, err)
+	}
+	if svc.calls[1] != 1 || svc.calls[2] != 1 {
+		t.Fatalf("expected one call per user, got %v", svc.calls)
+	}
+
+	clock.Advance(time.Second * 2)
+	_, _ = getUser(context.Background(), 1)
+	if svc.calls[1] != 2 {
+		t.Fatalf("expected a new call once the debounce elapsed, got %v", svc.calls)
+	}
+}
+
+func TestDebounceFuncSweep(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	svc := &userService{}
+	getUser := dist.DebounceFunc(svc.GetUser, time.Second, dist.WithClock(clock))
+
+	_, _ = getUser(context.Background(), 1)
+	clock.Advance(time.Millisecond * 1500)
+	// this call sweeps user 1, whose debounce is over, and keeps user 2
+	_, _ = getUser(context.Background(), 2)
+	clock.Advance(time.Millisecond * 500)
+	_, _ = getUser(context.Background(), 2)
+	if svc.calls[2] != 1 {
+		t.Fatalf("expected a sweep to keep a debounce still running, got %v", svc.calls)
+	}
+
+	_, _ = getUser(context.Background(), 1)
+	if svc.calls[1] != 2 {
+		t.Fatalf("expected a new call for a swept argument, got %v", svc.calls)
+	}
+}
+
+func TestCacheFunc(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	cache := dist.NewLRUCache[int, user](10, dist.WithClock(clock))
+	svc := &userService{}
+	getUser := dist.CacheFunc(svc.GetUser, cache, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		u, err := getUser(context.Background(), 1)
+		if err != nil || u.Name != "user-1" {
+			t.Fatalf("unexpected result %+v, %v", u, err)
+		}
+	}
+	if svc.calls[1] != 1 {
+		t.Fatalf("expected cached results, got %d calls", svc.calls[1])
+	}
+
+	clock.Advance(time.Minute * 2)
+	_, _ = getUser(context.Background(), 1)
+	if svc.calls[1] != 2 {
+		t.Fatalf("expected an expired result to be fetched again, got %d calls", svc.calls[1])
+	}
+
+	svc.fail = true
+	if _, err := getUser(context.Background(), 2); err == nil {
+		t.Fatal("expected an error")
+	}
+	if cache.Contains(2) {
+		t.Fatal("expected errors not to be cached")
+	}
+}
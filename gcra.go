@@ -0,0 +1,83 @@
+package dist
+
+import (
+	"sync"
+	"time"
+)
+
+// GCRA is a rate limiter implementing the generic cell rate algorithm. It
+// spaces calls evenly, one every window/limit, and only lets a call arrive
+// early by as much as the burst size allows. Unlike a token bucket refilled
+// every window, it never lets a whole window's worth of calls through at
+// once unless the burst size says so. It keeps a single timestamp.
+type GCRA struct {
+	interval  time.Duration
+	burst     int64
+	tolerance time.Duration
+	clock     Clock
+
+	mu sync.Mutex
+	// tat is the theoretical arrival time of the next call, the time it
+	// would arrive if calls came exactly one interval apart.
+	tat time.Time
+}
+
+// NewGCRA returns a limiter letting limit calls through per window, in
+// bursts of at most the size set with WithBurst, 1 by default.
+func NewGCRA(limit uint, window time.Duration, opts ...ThrottleOption) *GCRA {
+	cfg := newThrottleConfig(opts)
+	if limit == 0 {
+		limit = 1
+	}
+	if cfg.burst == 0 {
+		cfg.burst = 1
+	}
+	interval := window / time.Duration(limit)
+	if interval <= 0 {
+		interval = 1
+	}
+
+	return &GCRA{
+		interval:  interval,
+		burst:     int64(cfg.burst),
+		tolerance: interval * time.Duration(cfg.burst),
+		clock:     cfg.clock,
+	}
+}
+
+// TakeN lets a call costing n units through if it does not arrive too early,
+// or returns a *ThrottleError telling when to try again.
+func (g *GCRA) TakeN(n uint) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	if int64(n) > g.burst {
+		return g.rejection(now, tat, maxDuration)
+	}
+	next := tat.Add(g.interval * time.Duration(n))
+	if early := next.Sub(now) - g.tolerance; early > 0 {
+		return g.rejection(now, tat, early)
+	}
+
+	g.tat = next
+	return nil
+}
+
+// Limit returns the burst size.
+func (g *GCRA) Limit() int {
+	return int(g.burst)
+}
+
+func (g *GCRA) rejection(now time.Time, tat time.Time, retryAfter time.Duration) *ThrottleError {
+	return &ThrottleError{
+		RetryAfter: retryAfter,
+		Remaining:  int((g.tolerance - tat.Sub(now)) / g.interval),
+		Limit:      int(g.burst),
+	}
+}
@@ -0,0 +1,69 @@
+package dist_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestGCRASpacesCalls(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	g := dist.NewGCRA(10, time.Second, dist.WithClock(clock))
+
+	if err := g.TakeN(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := g.TakeN(1)
+	var te *dist.ThrottleError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected a throttle error, got %v", err)
+	}
+	if te.RetryAfter != time.Millisecond*100 {
+		t.Fatalf("expected to retry after 100ms, got %v", te.RetryAfter)
+	}
+
+	clock.Advance(time.Millisecond * 99)
+	if err := g.TakeN(1); err == nil {
+		t.Fatal("expected a call 99ms later to be rejected")
+	}
+	clock.Advance(time.Millisecond)
+	if err := g.TakeN(1); err != nil {
+		t.Fatalf("expected a call 100ms later to be let through, got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if err := g.TakeN(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := g.TakeN(1); err == nil {
+		t.Fatal("expected an idle limiter not to allow a burst")
+	}
+}
+
+func TestGCRABurst(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	g := dist.NewGCRA(10, time.Second, dist.WithClock(clock), dist.WithBurst(3))
+
+	for i := 0; i < 3; i++ {
+		if err := g.TakeN(1); err != nil {
+			t.Fatalf("expected call %d of the burst to be let through, got %v", i, err)
+		}
+	}
+	var te *dist.ThrottleError
+	if !errors.As(g.TakeN(1), &te) {
+		t.Fatal("expected the burst to be used up")
+	}
+	if te.Remaining != 0 || te.Limit != 3 {
+		t.Fatalf("expected 0 of 3 remaining, got %d of %d", te.Remaining, te.Limit)
+	}
+
+	clock.Advance(time.Millisecond * 200)
+	if err := g.TakeN(2); err != nil {
+		t.Fatalf("expected 2 units after 200ms, got %v", err)
+	}
+	if !errors.As(g.TakeN(4), &te) || te.RetryAfter <= time.Hour {
+		t.Fatal("expected a cost above the burst size never to be let through")
+	}
+}
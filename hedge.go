@@ -0,0 +1,192 @@
+package dist
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	hedgeLatencySamples    = 100
+	hedgeMinLatencySamples = 20
+)
+
+type hedgeConfig struct {
+	maxHedges  uint
+	percentile float64
+	budget     *RetryBudget
+	clock      Clock
+}
+
+// HedgeOption configures a hedge.
+type HedgeOption interface {
+	applyHedge(*hedgeConfig)
+}
+
+type hedgeOptionFunc func(*hedgeConfig)
+
+func (f hedgeOptionFunc) applyHedge(c *hedgeConfig) {
+	f(c)
+}
+
+// WithMaxHedges sets how many extra calls a hedge may make on top of the
+// first one, each one delay after the previous. The default is 1.
+func WithMaxHedges(n uint) HedgeOption {
+	return hedgeOptionFunc(func(c *hedgeConfig) {
+		c.maxHedges = n
+	})
+}
+
+// WithHedgePercentile makes a hedge wait for the given percentile of the
+// latency of its recent successful calls, such as 95, before making an extra
+// call, instead of a fixed delay. The fixed delay is used until the hedge has
+// seen enough calls.
+func WithHedgePercentile(percentile float64) HedgeOption {
+	return hedgeOptionFunc(func(c *hedgeConfig) {
+		c.percentile = percentile
+	})
+}
+
+// WithHedgeBudget makes a hedge draw every extra call from budget, like a
+// retry does, so that hedging cannot multiply the load on a dependency that
+// is slow across the board. A call that finds the budget exhausted carries
+// on waiting for the calls it has already made.
+func WithHedgeBudget(budget *RetryBudget) HedgeOption {
+	return hedgeOptionFunc(func(c *hedgeConfig) {
+		c.budget = budget
+	})
+}
+
+// Hedge wraps a circuit so that a call taking longer than delay is made again
+// while the first one is still running, and the first to succeed wins. The
+// calls that lose have their context canceled. A call that fails is not made
+// again; the hedge returns the first error once every call it made has
+// failed.
+func Hedge[T any](circuit Circuit[T], delay time.Duration, opts ...HedgeOption) Circuit[T] {
+	cfg := hedgeConfig{
+		maxHedges: 1,
+		clock:     RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyHedge(&cfg)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+
+	latencies := &latencyRecorder{}
+
+	type result struct {
+		res *T
+		err error
+	}
+
+	return func(ctx context.Context) (*T, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if cfg.budget != nil {
+			cfg.budget.Request()
+		}
+
+		results := make(chan result, cfg.maxHedges+1)
+		var cancels []context.CancelFunc
+		defer func() {
+			for _, cancel := range cancels {
+				cancel()
+			}
+		}()
+		call := func() {
+			callCtx, cancel := context.WithCancel(ctx)
+			cancels = append(cancels, cancel)
+			go func() {
+				res, err := circuit(callCtx)
+				results <- result{res, err}
+			}()
+		}
+
+		wait := delay
+		if cfg.percentile > 0 {
+			if d, ok := latencies.percentile(cfg.percentile); ok {
+				wait = d
+			}
+		}
+		// The latency recorded is that of the whole call, hedges included,
+		// so that the percentile tracks what callers see.
+		start := cfg.clock.Now()
+		timer := cfg.clock.NewTimer(wait)
+		defer timer.Stop()
+
+		call()
+		hedges, running := uint(0), 1
+		var firstErr error
+		for {
+			var hedge <-chan time.Time
+			if hedges < cfg.maxHedges {
+				hedge = timer.C()
+			}
+
+			select {
+			case r := <-results:
+				running--
+				if r.err == nil {
+					latencies.record(cfg.clock.Now().Sub(start))
+					return r.res, nil
+				}
+				if firstErr == nil {
+					firstErr = r.err
+				}
+				if running == 0 {
+					return nil, firstErr
+				}
+			case <-hedge:
+				hedges++
+				if cfg.budget != nil && !cfg.budget.TryRetry() {
+					hedges = cfg.maxHedges
+					continue
+				}
+				call()
+				running++
+				timer.Reset(wait)
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// latencyRecorder keeps the latencies of the most recent calls.
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (lr *latencyRecorder) record(d time.Duration) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if len(lr.samples) < hedgeLatencySamples {
+		lr.samples = append(lr.samples, d)
+		return
+	}
+	lr.samples[lr.next] = d
+	lr.next = (lr.next + 1) % len(lr.samples)
+}
+
+// percentile returns the given percentile of the recorded latencies, or false
+// if too few have been recorded.
+func (lr *latencyRecorder) percentile(p float64) (time.Duration, bool) {
+	lr.mu.Lock()
+	sorted := slices.Clone(lr.samples)
+	lr.mu.Unlock()
+
+	if len(sorted) < hedgeMinLatencySamples {
+		return 0, false
+	}
+	slices.Sort(sorted)
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))], true
+}
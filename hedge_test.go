@@ -0,0 +1,187 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+// slowFirstCircuit blocks its first call until its context ends and answers
+// every later call right away.
+type slowFirstCircuit struct {
+	calls    atomic.Int64
+	canceled chan struct{}
+}
+
+func (c *slowFirstCircuit) call(ctx context.Context) (*string, error) {
+	n := c.calls.Add(1)
+	if n == 1 {
+		<-ctx.Done()
+		close(c.canceled)
+		return nil, ctx.Err()
+	}
+	res := "hedge"
+	return &res, nil
+}
+
+func TestHedgeWinsOverSlowCall(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	circuit := &slowFirstCircuit{canceled: make(chan struct{})}
+	hedged := dist.Hedge(circuit.call, time.Millisecond*50, dist.WithClock(clock))
+
+	done := make(chan *string, 1)
+	go func() {
+		res, _ := hedged(context.Background())
+		done <- res
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Millisecond * 50)
+	if res := <-done; res == nil || *res != "hedge" {
+		t.Fatalf("expected the hedge to win, got %v", res)
+	}
+	<-circuit.canceled
+	if circuit.calls.Load() != 2 {
+		t.Fatalf("expected 2 calls, got %d", circuit.calls.Load())
+	}
+}
+
+func TestHedgeNotNeeded(t *testing.T) {
+	var calls atomic.Int64
+	hedged := dist.Hedge(func(context.Context) (*string, error) {
+		calls.Add(1)
+		return nil, errUnavailable
+	}, time.Minute)
+
+	if _, err := hedged(context.Background()); !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the error of the call, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected a failed call not to be hedged, got %d calls", calls.Load())
+	}
+}
+
+func TestHedgeBudget(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	budget := dist.NewRetryBudget(0, 0, time.Second, dist.WithClock(clock))
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	hedged := dist.Hedge(func(context.Context) (*string, error) {
+		calls.Add(1)
+		<-release
+		res := "ok"
+		return &res, nil
+	}, time.Millisecond*50, dist.WithClock(clock), dist.WithMaxHedges(2), dist.WithHedgeBudget(budget))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := hedged(context.Background())
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Millisecond * 50)
+	clock.Advance(time.Millisecond * 50)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected an exhausted budget to prevent hedging, got %d calls", calls.Load())
+	}
+}
+
+func TestHedgePercentile(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var slow atomic.Bool
+	var calls atomic.Int64
+	hedged := dist.Hedge(func(ctx context.Context) (*string, error) {
+		calls.Add(1)
+		if slow.CompareAndSwap(true, false) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		if calls.Load() <= 20 {
+			clock.Advance(time.Millisecond * 10)
+		}
+		res := "ok"
+		return &res, nil
+	}, time.Minute, dist.WithClock(clock), dist.WithHedgePercentile(95))
+
+	for i := 0; i < 20; i++ {
+		if _, err := hedged(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	slow.Store(true)
+	done := make(chan error, 1)
+	go func() {
+		_, err := hedged(context.Background())
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Millisecond * 10)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the hedge to win after the p95 latency, got %v", err)
+	}
+	if calls.Load() != 22 {
+		t.Fatalf("expected 22 calls, got %d", calls.Load())
+	}
+}
+
+func TestHedgePercentileCountsWholeCall(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	started := make(chan struct{}, 2)
+	var calls atomic.Int64
+	hedged := dist.Hedge(func(ctx context.Context) (*string, error) {
+		started <- struct{}{}
+		// every first attempt hangs and its hedge answers right away
+		if calls.Add(1)%2 == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		res := "ok"
+		return &res, nil
+	}, time.Millisecond*50, dist.WithClock(clock), dist.WithHedgePercentile(95))
+
+	call := func() chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := hedged(context.Background())
+			done <- err
+		}()
+		<-started
+		clock.BlockUntil(1)
+		return done
+	}
+
+	for i := 0; i < 20; i++ {
+		done := call()
+		clock.Advance(time.Millisecond * 50)
+		<-started
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// the hedges won in no time, but the calls took 50ms
+	done := call()
+	clock.Advance(time.Millisecond * 49)
+	select {
+	case <-started:
+		t.Fatal("expected the hedge to wait for the latency of the whole call")
+	case <-time.After(time.Millisecond * 20):
+	}
+	clock.Advance(time.Millisecond)
+	<-started
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
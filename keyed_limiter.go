@@ -0,0 +1,195 @@
+package dist
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const keyedLimiterShards = 32
+
+// WithIdleTTL makes a KeyedLimiter drop the bucket of a key once the key has
+// not been used for ttl. The next call with that key starts from a full
+// bucket, so a ttl shorter than the time the bucket takes to refill lets an
+// idle key burst earlier than it otherwise would. By default a bucket is
+// dropped once it would be full again.
+func WithIdleTTL(ttl time.Duration) ThrottleOption {
+	return throttleOptionFunc(func(c *throttleConfig) {
+		c.idleTTL = ttl
+	})
+}
+
+type limitKey struct{}
+
+// WithLimitKey returns a copy of ctx carrying the key that ThrottleByKey
+// limits the call by, such as an API key or a customer ID.
+func WithLimitKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, limitKey{}, key)
+}
+
+// LimitKeyFromContext returns the key set on ctx with WithLimitKey.
+func LimitKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(limitKey{}).(string)
+	return key, ok
+}
+
+// keyedBucket is the bucket of one key and the time, in Unix nanoseconds, it
+// was last used.
+type keyedBucket struct {
+	*TokenBucket
+	lastUsed atomic.Int64
+}
+
+// KeyedLimiter is a rate limiter keeping a separate TokenBucket for every
+// key, created on the first call with that key. Buckets of keys that have not
+// been used for a while are dropped to bound memory; the sweep runs on the
+// calling goroutine, at most once per idle TTL, so the limiter runs no
+// goroutine of its own.
+type KeyedLimiter struct {
+	maxTokens      uint
+	refillTokens   uint
+	refillDuration time.Duration
+	opts           []ThrottleOption
+	cfg            throttleConfig
+
+	buckets   *ShardedMap[*keyedBucket]
+	lastSweep atomic.Int64
+}
+
+// NewKeyedLimiter returns a limiter giving every key a bucket holding up to
+// maxTokens tokens and refilled with refillTokens tokens every
+// refillDuration.
+func NewKeyedLimiter(maxTokens uint, refillTokens uint, refillDuration time.Duration, opts ...ThrottleOption) *KeyedLimiter {
+	cfg := newThrottleConfig(opts)
+	if cfg.idleTTL <= 0 {
+		cfg.idleTTL = refillDuration
+		if refillTokens > 0 {
+			cfg.idleTTL *= time.Duration((maxTokens + refillTokens - 1) / refillTokens)
+		}
+	}
+	if cfg.idleTTL <= 0 {
+		cfg.idleTTL = time.Minute
+	}
+
+	kl := &KeyedLimiter{
+		maxTokens:      maxTokens,
+		refillTokens:   refillTokens,
+		refillDuration: refillDuration,
+		opts:           opts,
+		cfg:            cfg,
+		buckets:        NewShardedMap[*keyedBucket](keyedLimiterShards),
+	}
+	kl.lastSweep.Store(cfg.clock.Now().UnixNano())
+	return kl
+}
+
+// Allow takes a token from the bucket of key if there is one and reports
+// whether it did.
+func (kl *KeyedLimiter) Allow(key string) bool {
+	return kl.AllowN(key, 1)
+}
+
+// AllowN takes n tokens from the bucket of key if there are that many and
+// reports whether it did.
+func (kl *KeyedLimiter) AllowN(key string, n uint) bool {
+	return kl.bucket(key).AllowN(n)
+}
+
+// TakeN takes n tokens from the bucket of key if there are that many, or
+// returns a *ThrottleError telling when to try again.
+func (kl *KeyedLimiter) TakeN(key string, n uint) error {
+	return kl.bucket(key).TakeN(n)
+}
+
+// Wait blocks until it can take a token from the bucket of key or ctx ends,
+// like TokenBucket.Wait.
+func (kl *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	return kl.bucket(key).Wait(ctx)
+}
+
+// Tokens returns the number of tokens left in the bucket of key.
+func (kl *KeyedLimiter) Tokens(key string) int {
+	if b, ok := kl.buckets.Get(key); ok {
+		return b.Tokens()
+	}
+	return int(kl.maxTokens)
+}
+
+// Len returns the number of keys the limiter currently holds a bucket for.
+func (kl *KeyedLimiter) Len() int {
+	return kl.buckets.Len()
+}
+
+// bucket returns the bucket of key, creating it if needed, and marks it used.
+// A call racing with the sweep may use a bucket that has just been dropped;
+// that costs at most the tokens of that call.
+func (kl *KeyedLimiter) bucket(key string) *TokenBucket {
+	now := kl.cfg.clock.Now()
+	kl.sweep(now)
+
+	b := kl.buckets.GetOrSet(key, func() *keyedBucket {
+		b := &keyedBucket{
+			TokenBucket: NewTokenBucket(kl.maxTokens, kl.refillTokens, kl.refillDuration, kl.opts...),
+		}
+		b.lastUsed.Store(now.UnixNano())
+		return b
+	})
+	b.lastUsed.Store(now.UnixNano())
+	return b.TokenBucket
+}
+
+// sweep drops the buckets of idle keys if no sweep ran for an idle TTL.
+func (kl *KeyedLimiter) sweep(now time.Time) {
+	last := kl.lastSweep.Load()
+	if now.UnixNano()-last < int64(kl.cfg.idleTTL) {
+		return
+	}
+	if !kl.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	cutoff := now.Add(-kl.cfg.idleTTL).UnixNano()
+	kl.buckets.DeleteFunc(func(_ string, b *keyedBucket) bool {
+		return b.lastUsed.Load() <= cutoff
+	})
+}
+
+// ThrottleByKey wraps an effector with a KeyedLimiter, so that every call
+// takes a token from the bucket of the key that key derives from its
+// context. A nil key uses the key set with WithLimitKey, and the empty key
+// when there is none. Calls are rejected with a *ThrottleError, or wait for
+// their token if the limiter was created WithThrottleWait.
+func ThrottleByKey[T any](e Effector[T], kl *KeyedLimiter, key func(context.Context) string) Effector[T] {
+	key = limitKeyFunc(key)
+
+	return func(ctx context.Context) (*T, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		b := kl.bucket(key(ctx))
+		if kl.cfg.wait {
+			if err := b.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return e(ctx)
+		}
+
+		if err := b.take(1); err != nil {
+			return nil, err
+		}
+		return e(ctx)
+	}
+}
+
+// limitKeyFunc returns key, or a function returning the key set with
+// WithLimitKey if key is nil.
+func limitKeyFunc(key func(context.Context) string) func(context.Context) string {
+	if key != nil {
+		return key
+	}
+	return func(ctx context.Context) string {
+		k, _ := LimitKeyFromContext(ctx)
+		return k
+	}
+}
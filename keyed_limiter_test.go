@@ -0,0 +1,133 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestKeyedLimiterPerKey(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	kl := dist.NewKeyedLimiter(2, 1, time.Second, dist.WithClock(clock))
+
+	for i := 0; i < 2; i++ {
+		if !kl.Allow("alice") {
+			t.Fatalf("expected call %d of alice to be allowed", i)
+		}
+	}
+	if kl.Allow("alice") {
+		t.Fatal("expected alice to be limited")
+	}
+	if !kl.Allow("bob") {
+		t.Fatal("expected bob to have a bucket of his own")
+	}
+	if kl.Tokens("carol") != 2 {
+		t.Fatalf("expected an unknown key to have a full bucket, got %d", kl.Tokens("carol"))
+	}
+	if kl.Len() != 2 {
+		t.Fatalf("expected 2 buckets, got %d", kl.Len())
+	}
+
+	clock.Advance(time.Second)
+	if !kl.Allow("alice") {
+		t.Fatal("expected alice to be refilled")
+	}
+}
+
+func TestKeyedLimiterEvictsIdleKeys(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	kl := dist.NewKeyedLimiter(2, 1, time.Second, dist.WithClock(clock), dist.WithIdleTTL(time.Minute))
+
+	kl.Allow("alice")
+	kl.Allow("bob")
+
+	clock.Advance(time.Second * 30)
+	kl.Allow("bob")
+
+	clock.Advance(time.Second * 31)
+	kl.Allow("carol")
+	if kl.Len() != 2 {
+		t.Fatalf("expected alice to be evicted, got %d buckets", kl.Len())
+	}
+
+	clock.Advance(time.Second * 30)
+	kl.Allow("carol")
+	if kl.Len() != 2 {
+		t.Fatalf("expected no sweep within the idle TTL, got %d buckets", kl.Len())
+	}
+
+	clock.Advance(time.Second * 31)
+	kl.Allow("carol")
+	if kl.Len() != 1 {
+		t.Fatalf("expected only carol to be left, got %d buckets", kl.Len())
+	}
+}
+
+func TestKeyedLimiterDefaultIdleTTL(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	kl := dist.NewKeyedLimiter(10, 2, time.Second, dist.WithClock(clock))
+
+	kl.Allow("alice")
+	clock.Advance(time.Second * 4)
+	kl.Allow("bob")
+	if kl.Len() != 2 {
+		t.Fatalf("expected alice to be kept until her bucket is full, got %d buckets", kl.Len())
+	}
+
+	clock.Advance(time.Second)
+	kl.Allow("bob")
+	if kl.Len() != 1 {
+		t.Fatalf("expected alice to be evicted once her bucket is full, got %d buckets", kl.Len())
+	}
+}
+
+func TestThrottleByKey(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	kl := dist.NewKeyedLimiter(1, 1, time.Second, dist.WithClock(clock))
+
+	var calls int
+	effector := dist.ThrottleByKey(func(ctx context.Context) (*string, error) {
+		calls++
+		key, _ := dist.LimitKeyFromContext(ctx)
+		return &key, nil
+	}, kl, nil)
+
+	alice := dist.WithLimitKey(context.Background(), "alice")
+	bob := dist.WithLimitKey(context.Background(), "bob")
+
+	res, err := effector(alice)
+	if err != nil || *res != "alice" {
+		t.Fatalf("unexpected result %v, %v", res, err)
+	}
+	if _, err := effector(alice); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected alice to be throttled, got %v", err)
+	}
+	if _, err := effector(bob); err != nil {
+		t.Fatalf("expected bob to be let through, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestThrottleByKeyFunc(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	kl := dist.NewKeyedLimiter(1, 1, time.Second, dist.WithClock(clock))
+
+	effector := dist.ThrottleByKey(mockEffector, kl, func(context.Context) string {
+		return "tenant"
+	})
+	if _, err := effector(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := effector(context.Background()); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected the tenant to be throttled, got %v", err)
+	}
+	if kl.Tokens("tenant") != 0 {
+		t.Fatalf("expected the tenant bucket to be empty, got %d", kl.Tokens("tenant"))
+	}
+	mockEffectorCalled = 0
+}
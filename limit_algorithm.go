@@ -0,0 +1,161 @@
+package dist
+
+import (
+	"math"
+	"time"
+)
+
+// LimitSample is the outcome of a call made through an AdaptiveLimiter.
+type LimitSample struct {
+	// RTT is how long the call took.
+	RTT time.Duration
+	// InFlight is the number of calls in flight when the call started,
+	// itself included.
+	InFlight int
+	// Dropped is true if the call failed, a sign that the dependency is
+	// overloaded.
+	Dropped bool
+}
+
+// LimitAlgorithm computes the concurrency limit of an AdaptiveLimiter. Update
+// is called after every call with the current limit and returns the new one;
+// the limiter rounds it down and keeps it within its bounds. Algorithms may
+// keep state between calls, so every limiter needs an algorithm of its own.
+// The limiter never calls Update concurrently.
+type LimitAlgorithm interface {
+	Update(limit float64, s LimitSample) float64
+}
+
+// LimitAlgorithmFunc adapts an ordinary function to the LimitAlgorithm
+// interface.
+type LimitAlgorithmFunc func(limit float64, s LimitSample) float64
+
+func (f LimitAlgorithmFunc) Update(limit float64, s LimitSample) float64 {
+	return f(limit, s)
+}
+
+// NewAIMDLimit returns an additive increase, multiplicative decrease
+// algorithm. It raises the limit by one after every successful call made
+// while at least half the limit was in use, and multiplies it by
+// backoffRatio after every failure. A backoffRatio outside (0, 1) defaults
+// to 0.9. It ignores latency.
+func NewAIMDLimit(backoffRatio float64) LimitAlgorithm {
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.9
+	}
+
+	return LimitAlgorithmFunc(func(limit float64, s LimitSample) float64 {
+		if s.Dropped {
+			return limit * backoffRatio
+		}
+		if float64(s.InFlight)*2 >= limit {
+			return limit + 1
+		}
+		return limit
+	})
+}
+
+// NewVegasLimit returns an algorithm modelled on TCP Vegas. It takes the
+// lowest latency seen as the latency of an idle dependency, and estimates
+// from the latency of every call how many calls are queueing at the
+// dependency. It raises the limit while few calls queue, lowers it once too
+// many do, and backs off on failures.
+func NewVegasLimit() LimitAlgorithm {
+	return &vegasLimit{}
+}
+
+type vegasLimit struct {
+	minRTT time.Duration
+}
+
+func (v *vegasLimit) Update(limit float64, s LimitSample) float64 {
+	// Thresholds grow with the log of the limit, so that large limits
+	// move in steps proportionate to their size.
+	step := max(math.Log10(limit), 1)
+	// A failure lowers the limit however long it took, even if it failed
+	// at once.
+	if s.Dropped {
+		return limit - step
+	}
+	if s.RTT <= 0 {
+		return limit
+	}
+	if v.minRTT == 0 || s.RTT < v.minRTT {
+		v.minRTT = s.RTT
+	}
+	if float64(s.InFlight)*2 < limit {
+		return limit
+	}
+
+	queue := limit * (1 - float64(v.minRTT)/float64(s.RTT))
+	switch {
+	case queue <= 3*step:
+		return limit + step
+	case queue >= 6*step:
+		return limit - step
+	default:
+		return limit
+	}
+}
+
+// NewGradient2Limit returns an algorithm modelled on Netflix's Gradient2. It
+// compares the latency of every call with a long-term average latency: while
+// calls are not slower than tolerance times the average, the limit grows by
+// about its square root, and as they get slower it shrinks in proportion, by
+// at most half. The limit is smoothed so a single outlier moves it little.
+// A tolerance below 1 defaults to 1.5.
+func NewGradient2Limit(tolerance float64) LimitAlgorithm {
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	return &gradient2Limit{
+		tolerance: tolerance,
+		smoothing: 0.2,
+		// The long-term average covers about the last 600 samples.
+		decay: 2.0 / 601,
+	}
+}
+
+type gradient2Limit struct {
+	tolerance float64
+	smoothing float64
+	decay     float64
+
+	longRTT float64
+}
+
+func (g *gradient2Limit) Update(limit float64, s LimitSample) float64 {
+	// A failure halves the limit however long it took, even if it failed
+	// at once.
+	if s.Dropped {
+		return g.smooth(limit, 0.5)
+	}
+	if s.RTT <= 0 {
+		return limit
+	}
+	rtt := float64(s.RTT)
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	} else {
+		g.longRTT += (rtt - g.longRTT) * g.decay
+	}
+	// Recover quickly once the dependency is faster than it used to be,
+	// instead of waiting for the average to catch up.
+	if g.longRTT/rtt > 2 {
+		g.longRTT *= 0.95
+	}
+
+	// Do not grow a limit the callers are not using.
+	if float64(s.InFlight)*2 < limit {
+		return limit
+	}
+
+	return g.smooth(limit, max(0.5, min(1, g.tolerance*g.longRTT/rtt)))
+}
+
+// smooth moves the limit part of the way towards limit scaled by gradient
+// and grown by its square root.
+func (g *gradient2Limit) smooth(limit float64, gradient float64) float64 {
+	next := limit*gradient + math.Sqrt(limit)
+	return limit*(1-g.smoothing) + next*g.smoothing
+}
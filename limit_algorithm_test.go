@@ -0,0 +1,69 @@
+package dist_test
+
+import (
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestAIMDLimit(t *testing.T) {
+	alg := dist.NewAIMDLimit(0.5)
+
+	if got := alg.Update(10, dist.LimitSample{RTT: time.Millisecond, InFlight: 5}); got != 11 {
+		t.Fatalf("expected the limit to grow to 11, got %v", got)
+	}
+	if got := alg.Update(10, dist.LimitSample{RTT: time.Millisecond, InFlight: 2}); got != 10 {
+		t.Fatalf("expected an unused limit to stay at 10, got %v", got)
+	}
+	if got := alg.Update(10, dist.LimitSample{RTT: time.Millisecond, InFlight: 10, Dropped: true}); got != 5 {
+		t.Fatalf("expected the limit to halve to 5, got %v", got)
+	}
+}
+
+func TestVegasLimit(t *testing.T) {
+	alg := dist.NewVegasLimit()
+
+	limit := 10.0
+	for i := 0; i < 10; i++ {
+		limit = alg.Update(limit, dist.LimitSample{RTT: time.Millisecond * 10, InFlight: int(limit)})
+	}
+	if limit < 20 {
+		t.Fatalf("expected the limit to grow by at least one per call, got %v", limit)
+	}
+
+	// Latency doubles: half the calls in flight are queueing.
+	next := alg.Update(limit, dist.LimitSample{RTT: time.Millisecond * 20, InFlight: int(limit)})
+	if next >= limit {
+		t.Fatalf("expected the limit to shrink under queueing, got %v", next)
+	}
+
+	next = alg.Update(limit, dist.LimitSample{RTT: time.Millisecond * 10, InFlight: int(limit), Dropped: true})
+	if next >= limit {
+		t.Fatalf("expected the limit to shrink on failure, got %v", next)
+	}
+}
+
+func TestGradient2Limit(t *testing.T) {
+	alg := dist.NewGradient2Limit(1.5)
+
+	limit := 20.0
+	for i := 0; i < 50; i++ {
+		limit = alg.Update(limit, dist.LimitSample{RTT: time.Millisecond * 10, InFlight: int(limit)})
+	}
+	if limit <= 20 {
+		t.Fatalf("expected the limit to grow at steady latency, got %v", limit)
+	}
+
+	grown := limit
+	for i := 0; i < 20; i++ {
+		limit = alg.Update(limit, dist.LimitSample{RTT: time.Millisecond * 100, InFlight: int(limit)})
+	}
+	if limit >= grown/2 {
+		t.Fatalf("expected the limit to shrink as latency grows tenfold, got %v from %v", limit, grown)
+	}
+
+	if got := alg.Update(10, dist.LimitSample{RTT: time.Millisecond * 10, InFlight: 1}); got != 10 {
+		t.Fatalf("expected an unused limit to stay at 10, got %v", got)
+	}
+}
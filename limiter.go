@@ -0,0 +1,114 @@
+package dist
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limiter is a rate limiter. TokenBucket, GCRA, SlidingWindowLog and
+// SlidingWindowCounter all implement it, so the algorithm behind a throttle
+// can be chosen by configuration with NewLimiter.
+type Limiter interface {
+	// TakeN lets a call costing n units through, or returns a
+	// *ThrottleError telling when to try again.
+	TakeN(n uint) error
+	// Limit returns the most units the limiter lets through at once.
+	Limit() int
+}
+
+// LimiterAlgorithm names a rate-limiting algorithm for NewLimiter.
+type LimiterAlgorithm int
+
+const (
+	// AlgorithmTokenBucket lets bursts of up to the burst size through and
+	// refills a token every window/limit.
+	AlgorithmTokenBucket LimiterAlgorithm = iota
+	// AlgorithmGCRA spaces calls evenly over the window, allowing bursts of
+	// the burst size only, one call by default.
+	AlgorithmGCRA
+	// AlgorithmSlidingWindowLog lets at most limit calls through in any
+	// window-long period, remembering the time of every call.
+	AlgorithmSlidingWindowLog
+	// AlgorithmSlidingWindowCounter approximates the sliding window log
+	// with two counters, weighting the previous fixed window by how much of
+	// it still overlaps the sliding window.
+	AlgorithmSlidingWindowCounter
+)
+
+func (a LimiterAlgorithm) String() string {
+	switch a {
+	case AlgorithmTokenBucket:
+		return "token-bucket"
+	case AlgorithmGCRA:
+		return "gcra"
+	case AlgorithmSlidingWindowLog:
+		return "sliding-window-log"
+	case AlgorithmSlidingWindowCounter:
+		return "sliding-window-counter"
+	default:
+		return fmt.Sprintf("unknown algorithm %d", int(a))
+	}
+}
+
+// ParseLimiterAlgorithm returns the algorithm whose String is s.
+func ParseLimiterAlgorithm(s string) (LimiterAlgorithm, error) {
+	for a := AlgorithmTokenBucket; a <= AlgorithmSlidingWindowCounter; a++ {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("throttle: unknown algorithm %q", s)
+}
+
+// WithBurst sets how many units a token bucket or GCRA limiter lets through
+// at once. It defaults to the limit for a token bucket and to 1 for GCRA.
+// Sliding window limiters ignore it.
+func WithBurst(n uint) ThrottleOption {
+	return throttleOptionFunc(func(c *throttleConfig) {
+		c.burst = n
+	})
+}
+
+// NewLimiter returns a limiter letting limit units through per window using
+// the given algorithm.
+func NewLimiter(alg LimiterAlgorithm, limit uint, window time.Duration, opts ...ThrottleOption) (Limiter, error) {
+	switch alg {
+	case AlgorithmTokenBucket:
+		burst := newThrottleConfig(opts).burst
+		if burst == 0 {
+			burst = limit
+		}
+		// One token every window/limit keeps the rate at limit per window
+		// whatever the burst size.
+		interval := window
+		if limit > 0 {
+			interval = max(window/time.Duration(limit), 1)
+		}
+		return NewTokenBucket(burst, 1, interval, opts...), nil
+	case AlgorithmGCRA:
+		return NewGCRA(limit, window, opts...), nil
+	case AlgorithmSlidingWindowLog:
+		return NewSlidingWindowLog(limit, window, opts...), nil
+	case AlgorithmSlidingWindowCounter:
+		return NewSlidingWindowCounter(limit, window, opts...), nil
+	default:
+		return nil, fmt.Errorf("throttle: unknown algorithm %v", alg)
+	}
+}
+
+// Throttle wraps an effector with a limiter. Every call takes one unit;
+// calls the limiter rejects return its *ThrottleError without calling the
+// effector.
+func Throttle[T any](e Effector[T], l Limiter) Effector[T] {
+	return func(ctx context.Context) (*T, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err := l.TakeN(1); err != nil {
+			return nil, err
+		}
+		return e(ctx)
+	}
+}
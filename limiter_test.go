@@ -0,0 +1,104 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestNewLimiter(t *testing.T) {
+	algorithms := []dist.LimiterAlgorithm{
+		dist.AlgorithmTokenBucket,
+		dist.AlgorithmGCRA,
+		dist.AlgorithmSlidingWindowLog,
+		dist.AlgorithmSlidingWindowCounter,
+	}
+	for _, alg := range algorithms {
+		t.Run(alg.String(), func(t *testing.T) {
+			parsed, err := dist.ParseLimiterAlgorithm(alg.String())
+			if err != nil || parsed != alg {
+				t.Fatalf("expected to parse %v, got %v, %v", alg, parsed, err)
+			}
+
+			clock := dist.NewFakeClock(time.Now())
+			l, err := dist.NewLimiter(alg, 5, time.Second, dist.WithClock(clock))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			allowed := 0
+			for i := 0; i < 20; i++ {
+				if l.TakeN(1) == nil {
+					allowed++
+				}
+				clock.Advance(time.Millisecond * 100)
+			}
+			if allowed < 8 || allowed > 15 {
+				t.Fatalf("expected about 5 calls a second over 2s, got %d", allowed)
+			}
+		})
+	}
+
+	if _, err := dist.ParseLimiterAlgorithm("leaky"); err == nil {
+		t.Fatal("expected an unknown algorithm to fail to parse")
+	}
+	if _, err := dist.NewLimiter(dist.LimiterAlgorithm(42), 5, time.Second); err == nil {
+		t.Fatal("expected an unknown algorithm to be rejected")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	l, _ := dist.NewLimiter(dist.AlgorithmGCRA, 2, time.Second, dist.WithClock(clock))
+
+	var calls int
+	effector := dist.Throttle(func(context.Context) (*string, error) {
+		calls++
+		res := "ok"
+		return &res, nil
+	}, l)
+
+	if _, err := effector(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := effector(context.Background()); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled, got %v", err)
+	}
+	clock.Advance(time.Millisecond * 500)
+	if _, err := effector(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestNewLimiterTokenBucketBurst(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	l, err := dist.NewLimiter(dist.AlgorithmTokenBucket, 100, time.Second, dist.WithClock(clock), dist.WithBurst(10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	burst := 0
+	for l.TakeN(1) == nil {
+		burst++
+	}
+	if burst != 10 {
+		t.Fatalf("expected a burst of 10, got %d", burst)
+	}
+
+	allowed := 0
+	for i := 0; i < 1000; i++ {
+		clock.Advance(time.Millisecond)
+		if l.TakeN(1) == nil {
+			allowed++
+		}
+	}
+	if allowed != 100 {
+		t.Fatalf("expected 100 calls in a second, got %d", allowed)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strings"
 	"sync"
-	"time"
 )
 
 type LRUCache[K comparable, V any] struct {
@@ -14,6 +13,16 @@ type LRUCache[K comparable, V any] struct {
 	exp      *PriorityQueue[K]
 	mu       sync.RWMutex
 	ecb      EvictedCB[K, V]
+	clock    Clock
+}
+
+type cacheConfig struct {
+	clock Clock
+}
+
+// CacheOption configures an LRUCache.
+type CacheOption interface {
+	applyCache(*cacheConfig)
 }
 
 type EvictedCB[K comparable, V any] func(key K, item *CacheItem[V])
@@ -28,23 +37,28 @@ func (ci CacheItem[V]) String() string {
 	return fmt.Sprintf("value: %v, priority: %d, expiry: %d", ci.Value, ci.Priority, ci.Expiry)
 }
 
-func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
-	return &LRUCache[K, V]{
-		capacity: capacity,
-		data:     make(map[K]*CacheItem[V]),
-		lru:      NewPriorityQueue[K](capacity),
-		exp:      NewPriorityQueue[K](capacity),
-		ecb:      nil,
-	}
+func NewLRUCache[K comparable, V any](capacity int, opts ...CacheOption) *LRUCache[K, V] {
+	return NewLRUCacheWithEvict[K, V](capacity, nil, opts...)
 }
 
-func NewLRUCacheWithEvict[K comparable, V any](capacity int, onEvicted EvictedCB[K, V]) *LRUCache[K, V] {
+func NewLRUCacheWithEvict[K comparable, V any](capacity int, onEvicted EvictedCB[K, V], opts ...CacheOption) *LRUCache[K, V] {
+	cfg := cacheConfig{
+		clock: RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyCache(&cfg)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+
 	return &LRUCache[K, V]{
 		capacity: capacity,
 		data:     make(map[K]*CacheItem[V]),
 		lru:      NewPriorityQueue[K](capacity),
 		exp:      NewPriorityQueue[K](capacity),
 		ecb:      onEvicted,
+		clock:    cfg.clock,
 	}
 }
 
@@ -55,7 +69,7 @@ func (lc *LRUCache[K, V]) Get(key K) (*CacheItem[V], bool) {
 	ci, ok := lc.data[key]
 	if ok {
 		_ = lc.lru.RemoveAt(key)
-		_ = lc.lru.Push(key, ci.Priority, time.Now().Unix())
+		_ = lc.lru.Push(key, ci.Priority, lc.clock.Now().Unix())
 	}
 	return ci, ok
 }
@@ -67,7 +81,7 @@ func (lc *LRUCache[K, V]) Add(key K, value V, priority int64, expiry int64) (evi
 	_, ok := lc.data[key]
 	if ok {
 		_ = lc.lru.RemoveAt(key)
-		_ = lc.lru.Push(key, priority, time.Now().Unix())
+		_ = lc.lru.Push(key, priority, lc.clock.Now().Unix())
 		_ = lc.exp.RemoveAt(key)
 		_ = lc.exp.Push(key, expiry, 0)
 		lc.data[key] = &CacheItem[V]{
@@ -81,7 +95,7 @@ func (lc *LRUCache[K, V]) Add(key K, value V, priority int64, expiry int64) (evi
 	// cleanup strategy remove all expired items
 	if len(lc.data) == lc.capacity {
 		item, err := lc.exp.Peek()
-		for err == nil && item.Priority < time.Now().Unix() {
+		for err == nil && item.Priority < lc.clock.Now().Unix() {
 			expiredItem, _ := lc.exp.Pop()
 			lc.purge(expiredItem.Key)
 			evicted = true
@@ -103,7 +117,7 @@ func (lc *LRUCache[K, V]) Add(key K, value V, priority int64, expiry int64) (evi
 		Priority: priority,
 		Expiry:   expiry,
 	}
-	_ = lc.lru.Push(key, priority, time.Now().Unix())
+	_ = lc.lru.Push(key, priority, lc.clock.Now().Unix())
 	_ = lc.exp.Push(key, expiry, 0)
 
 	return evicted
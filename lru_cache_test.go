@@ -76,3 +76,26 @@ func LRUCacheFixture(t *testing.T) *dist.LRUCache[string, int] {
 	lc.Add("c", 3, 3000, time.Now().Unix()+30000)
 	return lc
 }
+
+func TestLRUCacheExpiryWithClock(t *testing.T) {
+	t.Parallel()
+	clock := dist.NewFakeClock(time.Now())
+	var evicted []string
+	lc := dist.NewLRUCacheWithEvict[string, int](2, func(key string, item *dist.CacheItem[int]) {
+		evicted = append(evicted, key)
+	}, dist.WithClock(clock))
+
+	lc.Add("a", 1, 1000, clock.Now().Unix()+10)
+	lc.Add("b", 2, 2000, clock.Now().Unix()+60)
+
+	clock.Advance(time.Second * 30)
+	if !lc.Add("c", 3, 3000, clock.Now().Unix()+60) {
+		t.Fatal("expected an eviction")
+	}
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("expected expired item a to be evicted, got %v", evicted)
+	}
+	if !lc.Contains("b") || !lc.Contains("c") {
+		t.Fatal("expected items b and c to be present")
+	}
+}
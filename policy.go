@@ -0,0 +1,138 @@
+package dist
+
+import "time"
+
+// Middleware wraps a circuit in another circuit adding some behaviour, such
+// as a breaker or a retry.
+type Middleware[T any] func(Circuit[T]) Circuit[T]
+
+// Chain returns a middleware applying every given middleware in turn, the
+// first one outermost: Chain(a, b)(c) is a(b(c)).
+func Chain[T any](mws ...Middleware[T]) Middleware[T] {
+	return func(circuit Circuit[T]) Circuit[T] {
+		for i := len(mws) - 1; i >= 0; i-- {
+			circuit = mws[i](circuit)
+		}
+		return circuit
+	}
+}
+
+// Policy combines the resilience wrappers of this package in a fixed order,
+// whatever order they are added in. From the outermost to the innermost, a
+// call goes through:
+//
+//  1. the retry, so that every attempt goes through all of the below;
+//  2. the throttle, so that every attempt, retries included, takes a token;
+//  3. the bulkhead, so that calls rejected for lack of a slot do not count
+//     against the breaker;
+//  4. the breaker, so that it sees timeouts as failures;
+//  5. the timeout, so that it bounds each attempt on its own;
+//  6. the middlewares added with Use, in the order they were added;
+//
+// and then reaches the circuit. A policy holds no state of its own: Apply
+// creates a new breaker, bulkhead and throttle for every circuit, so a
+// single policy can be applied to any number of circuits.
+type Policy[T any] struct {
+	retry    []RetryOption
+	hasRetry bool
+
+	throttle Middleware[T]
+
+	bulkhead    []BulkheadOption
+	maxInFlight uint
+
+	breaker    []BreakerOption
+	hasBreaker bool
+
+	timeout     time.Duration
+	timeoutOpts []TimeoutOption
+
+	mws []Middleware[T]
+}
+
+// NewPolicy returns an empty policy, which leaves circuits as they are.
+func NewPolicy[T any]() *Policy[T] {
+	return &Policy[T]{}
+}
+
+// WithRetry retries calls as Retry does.
+func (p *Policy[T]) WithRetry(opts ...RetryOption) *Policy[T] {
+	p.retry = opts
+	p.hasRetry = true
+	return p
+}
+
+// WithThrottle throttles calls as ThrottleWithRefill does.
+func (p *Policy[T]) WithThrottle(maxTokens uint, refillTokens uint, refillDuration time.Duration, opts ...ThrottleOption) *Policy[T] {
+	p.throttle = func(c Circuit[T]) Circuit[T] {
+		return ThrottleWithRefill(c, maxTokens, refillTokens, refillDuration, opts...)
+	}
+	return p
+}
+
+// WithBulkhead caps the calls in flight as NewBulkhead does.
+func (p *Policy[T]) WithBulkhead(maxInFlight uint, opts ...BulkheadOption) *Policy[T] {
+	p.maxInFlight = maxInFlight
+	p.bulkhead = opts
+	return p
+}
+
+// WithBreaker guards calls with a circuit breaker as NewCircuitBreaker does.
+func (p *Policy[T]) WithBreaker(opts ...BreakerOption) *Policy[T] {
+	p.breaker = opts
+	p.hasBreaker = true
+	return p
+}
+
+// WithTimeout bounds every attempt to d as Timeout does.
+func (p *Policy[T]) WithTimeout(d time.Duration, opts ...TimeoutOption) *Policy[T] {
+	p.timeout = d
+	p.timeoutOpts = opts
+	return p
+}
+
+// Use adds middlewares wrapping the circuit inside every other wrapper of the
+// policy.
+func (p *Policy[T]) Use(mws ...Middleware[T]) *Policy[T] {
+	p.mws = append(p.mws, mws...)
+	return p
+}
+
+// Apply wraps circuit with the policy.
+func (p *Policy[T]) Apply(circuit Circuit[T]) Circuit[T] {
+	return Chain(p.middlewares()...)(circuit)
+}
+
+// Middleware returns the policy as a middleware.
+func (p *Policy[T]) Middleware() Middleware[T] {
+	return p.Apply
+}
+
+// middlewares returns the wrappers of the policy, outermost first.
+func (p *Policy[T]) middlewares() []Middleware[T] {
+	var mws []Middleware[T]
+	if p.hasRetry {
+		mws = append(mws, func(c Circuit[T]) Circuit[T] {
+			return Retry(c, p.retry...)
+		})
+	}
+	if p.throttle != nil {
+		mws = append(mws, p.throttle)
+	}
+	if p.maxInFlight > 0 {
+		mws = append(mws, func(c Circuit[T]) Circuit[T] {
+			return NewBulkhead(c, p.maxInFlight, p.bulkhead...).Execute
+		})
+	}
+	if p.hasBreaker {
+		mws = append(mws, func(c Circuit[T]) Circuit[T] {
+			return NewCircuitBreaker(c, p.breaker...).Execute
+		})
+	}
+	if p.timeout > 0 {
+		mws = append(mws, func(c Circuit[T]) Circuit[T] {
+			return Timeout(c, p.timeout, p.timeoutOpts...)
+		})
+	}
+	return append(mws, p.mws...)
+}
@@ -0,0 +1,149 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func tracing(trace *[]string, name string) dist.Middleware[string] {
+	return func(c dist.Circuit[string]) dist.Circuit[string] {
+		return func(ctx context.Context) (*string, error) {
+			*trace = append(*trace, name)
+			return c(ctx)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	circuit := dist.Chain(tracing(&trace, "a"), tracing(&trace, "b"))(func(context.Context) (*string, error) {
+		trace = append(trace, "circuit")
+		return nil, nil
+	})
+
+	_, _ = circuit(context.Background())
+	if len(trace) != 3 || trace[0] != "a" || trace[1] != "b" || trace[2] != "circuit" {
+		t.Fatalf("expected a, b, circuit, got %v", trace)
+	}
+}
+
+func TestPolicyRetriesThroughBreaker(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var trace []string
+	policy := dist.NewPolicy[string]().
+		WithBreaker(dist.WithClock(clock), dist.WithFailureThreshold(2), dist.WithOpenTimeout(time.Minute)).
+		WithRetry(dist.WithMaxAttempts(5), dist.WithBackoff(dist.NewConstantBackoff(0))).
+		Use(tracing(&trace, "call"))
+
+	failing := &flakyCircuit{}
+	failing.fail.Store(true)
+	circuit := policy.Apply(failing.call)
+
+	_, err := circuit(context.Background())
+	var re *dist.RetryError
+	if !errors.As(err, &re) || len(re.Errors) != 5 {
+		t.Fatalf("expected 5 attempts, got %v", err)
+	}
+	if !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected the breaker to open between attempts, got %v", err)
+	}
+	if len(trace) != 2 {
+		t.Fatalf("expected the open breaker to stop calls, got %d calls", len(trace))
+	}
+
+	// Every circuit gets a breaker of its own.
+	failing.fail.Store(false)
+	other := policy.Apply(failing.call)
+	if _, err := other(context.Background()); err != nil {
+		t.Fatalf("expected a fresh breaker, got %v", err)
+	}
+}
+
+func TestPolicyTimeoutCountsAgainstBreaker(t *testing.T) {
+	policy := dist.NewPolicy[string]().
+		WithTimeout(time.Millisecond*10).
+		WithBreaker(dist.WithFailureThreshold(1), dist.WithOpenTimeout(time.Minute))
+
+	circuit := policy.Apply(func(ctx context.Context) (*string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if _, err := circuit(context.Background()); !errors.Is(err, dist.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if _, err := circuit(context.Background()); !errors.Is(err, dist.ErrCircuitOpen) {
+		t.Fatalf("expected the timeout to trip the breaker, got %v", err)
+	}
+}
+
+func TestPolicyBulkheadAndThrottle(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	release := make(chan struct{})
+	started := make(chan struct{})
+	policy := dist.NewPolicy[string]().
+		WithBulkhead(1).
+		WithThrottle(2, 1, time.Minute, dist.WithClock(clock)).
+		WithBreaker(dist.WithFailureThreshold(1))
+
+	circuit := policy.Apply(func(context.Context) (*string, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := circuit(context.Background())
+		done <- err
+	}()
+	<-started
+
+	if _, err := circuit(context.Background()); !errors.Is(err, dist.ErrBulkheadFull) {
+		t.Fatalf("expected ErrBulkheadFull, got %v", err)
+	}
+	if _, err := circuit(context.Background()); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected a full bulkhead not to trip the breaker, got %v", err)
+	}
+}
+
+func TestPolicyThrottleWait(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	policy := dist.NewPolicy[string]().
+		WithThrottle(1, 1, time.Second, dist.WithClock(clock), dist.WithThrottleWait())
+	circuit := policy.Apply(mockEffector)
+	defer func() { mockEffectorCalled = 0 }()
+
+	if _, err := circuit(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := circuit(context.Background())
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the call to wait for its token, got %v", err)
+	}
+}
+
+func TestEffectorIsCircuit(t *testing.T) {
+	var c dist.Circuit[string] = mockEffector
+	throttled := dist.ThrottleWithRefill(c, 1, 1, time.Minute)
+	breaker := dist.Breaker(throttled, 1)
+	if _, err := breaker(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mockEffectorCalled = 0
+}
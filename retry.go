@@ -0,0 +1,208 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultMaxAttempts  = 3
+	defaultRetryBase    = time.Millisecond * 100
+	defaultRetryBackoff = time.Second * 10
+)
+
+// RetryError is returned by a retry whose every attempt failed. It wraps the
+// errors of all attempts, in order, followed by the context error if the
+// context ended between attempts, so errors.Is and errors.As match any of
+// them.
+type RetryError struct {
+	Errors []error
+}
+
+func (e *RetryError) Error() string {
+	if len(e.Errors) == 0 {
+		return "retry: failed"
+	}
+	return fmt.Sprintf("retry: %d errors, last: %v", len(e.Errors), e.Errors[len(e.Errors)-1])
+}
+
+func (e *RetryError) Unwrap() []error {
+	return e.Errors
+}
+
+// OnRetryFunc is called before the retry waits for delay and makes another
+// attempt. attempt is the number of the attempt that failed with err,
+// counting from 1.
+type OnRetryFunc func(attempt uint, err error, delay time.Duration)
+
+type retryConfig struct {
+	maxAttempts    uint
+	maxElapsed     time.Duration
+	backoff        Backoff
+	retryIf        func(error) bool
+	attemptTimeout time.Duration
+	onRetry        OnRetryFunc
+	budget         *RetryBudget
+	clock          Clock
+}
+
+// RetryOption configures a retry.
+type RetryOption interface {
+	applyRetry(*retryConfig)
+}
+
+type retryOptionFunc func(*retryConfig)
+
+func (f retryOptionFunc) applyRetry(c *retryConfig) {
+	f(c)
+}
+
+// WithMaxAttempts sets how many attempts a call gets, the first one
+// included. The default is 3.
+func WithMaxAttempts(n uint) RetryOption {
+	return retryOptionFunc(func(c *retryConfig) {
+		c.maxAttempts = n
+	})
+}
+
+// WithMaxElapsed stops retrying once the next attempt would start more than
+// d after the first one. By default only the number of attempts is limited.
+func WithMaxElapsed(d time.Duration) RetryOption {
+	return retryOptionFunc(func(c *retryConfig) {
+		c.maxElapsed = d
+	})
+}
+
+// WithRetryIf sets the predicate that decides whether an attempt that failed
+// with an error is retried. The default is IsFailure, so that permanent
+// errors and canceled calls are not retried.
+func WithRetryIf(fn func(error) bool) RetryOption {
+	return retryOptionFunc(func(c *retryConfig) {
+		c.retryIf = fn
+	})
+}
+
+// WithAttemptTimeout bounds every attempt to d, on top of the deadline of the
+// caller's context, measured on the clock of the retry. An attempt that times
+// out is retried like any other failure while the caller's context lasts.
+func WithAttemptTimeout(d time.Duration) RetryOption {
+	return retryOptionFunc(func(c *retryConfig) {
+		c.attemptTimeout = d
+	})
+}
+
+// WithOnRetry registers a callback invoked before every re-attempt, for
+// logging and metrics.
+func WithOnRetry(fn OnRetryFunc) RetryOption {
+	return retryOptionFunc(func(c *retryConfig) {
+		c.onRetry = fn
+	})
+}
+
+// Retry wraps a circuit so that calls failing with a retryable error are
+// attempted again, waiting between attempts as the backoff says, by default
+// a full jitter backoff starting at 100ms and capped at 10s. It gives up once
+// it runs out of attempts, time or retry budget, an attempt fails with an
+// error that is not retryable, or the caller's context ends, and then
+// returns a *RetryError.
+func Retry[T any](circuit Circuit[T], opts ...RetryOption) Circuit[T] {
+	cfg := retryConfig{
+		maxAttempts: defaultMaxAttempts,
+		backoff:     NewFullJitterBackoff(defaultRetryBase, defaultRetryBackoff),
+		retryIf:     IsFailure,
+		clock:       RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyRetry(&cfg)
+	}
+	if cfg.maxAttempts == 0 {
+		cfg.maxAttempts = 1
+	}
+	if cfg.backoff == nil {
+		cfg.backoff = NewFullJitterBackoff(defaultRetryBase, defaultRetryBackoff)
+	}
+	if cfg.retryIf == nil {
+		cfg.retryIf = IsFailure
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+
+	return func(ctx context.Context) (*T, error) {
+		start := cfg.clock.Now()
+		if cfg.budget != nil {
+			cfg.budget.Request()
+		}
+		var errs []error
+		var delay time.Duration
+
+		for n := uint(1); ; n++ {
+			res, err := retryAttempt(ctx, &cfg, circuit)
+			if err == nil {
+				return res, nil
+			}
+			errs = append(errs, err)
+
+			if ctx.Err() != nil {
+				if !errors.Is(err, ctx.Err()) {
+					errs = append(errs, ctx.Err())
+				}
+				return nil, &RetryError{Errors: errs}
+			}
+			if n >= cfg.maxAttempts || !cfg.retryIf(err) {
+				return nil, &RetryError{Errors: errs}
+			}
+
+			delay = cfg.backoff.Next(n, delay)
+			if cfg.maxElapsed > 0 && cfg.clock.Now().Add(delay).Sub(start) > cfg.maxElapsed {
+				return nil, &RetryError{Errors: errs}
+			}
+			if cfg.budget != nil && !cfg.budget.TryRetry() {
+				return nil, &RetryError{Errors: append(errs, ErrRetryBudgetExhausted)}
+			}
+			if cfg.onRetry != nil {
+				cfg.onRetry(n, err, delay)
+			}
+			if err := cfg.sleep(ctx, delay); err != nil {
+				return nil, &RetryError{Errors: append(errs, err)}
+			}
+		}
+	}
+}
+
+// retryAttempt calls circuit once, bounded by the attempt timeout on the
+// clock of the retry.
+func retryAttempt[T any](ctx context.Context, cfg *retryConfig, circuit Circuit[T]) (*T, error) {
+	if cfg.attemptTimeout <= 0 {
+		return circuit(ctx)
+	}
+
+	attemptCtx, cancel := withClockTimeout(ctx, cfg.clock, cfg.attemptTimeout, context.DeadlineExceeded)
+	defer cancel()
+	res, err := circuit(attemptCtx)
+	// Off the real clock the attempt context is canceled rather than past
+	// its deadline; report the timeout as the deadline it stands for.
+	if errors.Is(err, context.Canceled) && ctx.Err() == nil && errors.Is(context.Cause(attemptCtx), context.DeadlineExceeded) {
+		return nil, context.DeadlineExceeded
+	}
+	return res, err
+}
+
+// sleep waits for d on the clock, or until ctx ends.
+func (cfg *retryConfig) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := cfg.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
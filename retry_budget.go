@@ -0,0 +1,112 @@
+package dist
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is wrapped by the error of a retry that gave up
+// because its RetryBudget allowed no further attempt.
+var ErrRetryBudgetExhausted = errors.New("retry: budget exhausted")
+
+const (
+	defaultBudgetSpan  = time.Second * 10
+	retryBudgetBuckets = 10
+)
+
+type retryBudgetConfig struct {
+	clock Clock
+}
+
+// RetryBudgetOption configures a RetryBudget.
+type RetryBudgetOption interface {
+	applyRetryBudget(*retryBudgetConfig)
+}
+
+// RetryBudget caps the retries made by all the calls sharing it to a
+// fraction of the calls made recently, so that retries cannot multiply the
+// load on a dependency that is already struggling. A small number of retries
+// per second is allowed on top, so that a quiet dependency can still be
+// retried at all.
+type RetryBudget struct {
+	ratio   float64
+	minimum float64
+	clock   Clock
+
+	mu sync.Mutex
+	// window counts every call and every retry as a call, and every retry
+	// as a failure.
+	window *rollingWindow
+}
+
+// NewRetryBudget returns a budget allowing, over any period of span, retries
+// up to ratio times the calls made in that period plus minPerSecond retries
+// per second. A span of zero or less defaults to 10s.
+func NewRetryBudget(ratio float64, minPerSecond uint, span time.Duration, opts ...RetryBudgetOption) *RetryBudget {
+	cfg := retryBudgetConfig{
+		clock: RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyRetryBudget(&cfg)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+	if span <= 0 {
+		span = defaultBudgetSpan
+	}
+
+	return &RetryBudget{
+		ratio:   ratio,
+		minimum: float64(minPerSecond) * span.Seconds(),
+		clock:   cfg.clock,
+		window:  newTimeWindow(span, retryBudgetBuckets),
+	}
+}
+
+// Request records a call made for the first time, adding to the retries the
+// budget allows.
+func (b *RetryBudget) Request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.window.record(b.clock.Now(), false, false)
+}
+
+// TryRetry records a retry and reports true if the budget allows one more,
+// and reports false otherwise.
+func (b *RetryBudget) TryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if b.balance(now) < 1 {
+		return false
+	}
+	b.window.record(now, true, false)
+	return true
+}
+
+// Balance returns the number of retries the budget currently allows.
+func (b *RetryBudget) Balance() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return int(max(b.balance(b.clock.Now()), 0))
+}
+
+func (b *RetryBudget) balance(now time.Time) float64 {
+	t := b.window.totals(now)
+	requests := float64(t.calls - t.failures)
+	return requests*b.ratio + b.minimum - float64(t.failures)
+}
+
+// WithRetryBudget makes a retry draw every re-attempt from budget, which
+// can be shared by any number of retries. A call that finds the budget
+// exhausted gives up with an error wrapping ErrRetryBudgetExhausted.
+func WithRetryBudget(budget *RetryBudget) RetryOption {
+	return retryOptionFunc(func(c *retryConfig) {
+		c.budget = budget
+	})
+}
@@ -0,0 +1,70 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestRetryBudget(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewRetryBudget(0.2, 1, time.Second*10, dist.WithClock(clock))
+
+	if b.Balance() != 10 {
+		t.Fatalf("expected the minimum of 10 retries, got %d", b.Balance())
+	}
+	for i := 0; i < 50; i++ {
+		b.Request()
+	}
+	if b.Balance() != 20 {
+		t.Fatalf("expected 20 retries after 50 calls, got %d", b.Balance())
+	}
+
+	for i := 0; i < 20; i++ {
+		if !b.TryRetry() {
+			t.Fatalf("expected retry %d to be allowed", i)
+		}
+	}
+	if b.TryRetry() {
+		t.Fatal("expected the budget to be exhausted")
+	}
+
+	clock.Advance(time.Second * 11)
+	if b.Balance() != 10 {
+		t.Fatalf("expected the window to forget old calls, got %d", b.Balance())
+	}
+}
+
+func TestRetryWithBudget(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	budget := dist.NewRetryBudget(0.5, 0, time.Second*10, dist.WithClock(clock))
+
+	calls := 0
+	r := dist.Retry(func(context.Context) (*string, error) {
+		calls++
+		return nil, errUnavailable
+	},
+		dist.WithClock(clock),
+		dist.WithMaxAttempts(5),
+		dist.WithBackoff(dist.NewConstantBackoff(0)),
+		dist.WithRetryBudget(budget),
+	)
+
+	// Each call earns half a retry, so the first call cannot retry and the
+	// second retries once.
+	_, err := r(context.Background())
+	if !errors.Is(err, dist.ErrRetryBudgetExhausted) || calls != 1 {
+		t.Fatalf("expected the budget to stop the first call, got %v after %d calls", err, calls)
+	}
+	calls = 0
+	_, err = r(context.Background())
+	if !errors.Is(err, dist.ErrRetryBudgetExhausted) || !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the budget to stop the second call, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls)
+	}
+}
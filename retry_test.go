@@ -0,0 +1,211 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// failingCircuit fails its first n calls with the given errors, in turn, and
+// succeeds afterwards.
+type failingCircuit struct {
+	errs  []error
+	calls int
+}
+
+func (c *failingCircuit) call(ctx context.Context) (*string, error) {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return nil, c.errs[c.calls-1]
+	}
+	res := "ok"
+	return &res, nil
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	circuit := &failingCircuit{errs: []error{errUnavailable, errUnavailable}}
+
+	type retry struct {
+		attempt uint
+		err     error
+		delay   time.Duration
+	}
+	var retries []retry
+	r := dist.Retry(circuit.call,
+		dist.WithBackoff(dist.NewLinearBackoff(time.Millisecond, 0)),
+		dist.WithOnRetry(func(attempt uint, err error, delay time.Duration) {
+			retries = append(retries, retry{attempt, err, delay})
+		}),
+	)
+
+	res, err := r(context.Background())
+	if err != nil || *res != "ok" {
+		t.Fatalf("unexpected result %v, %v", res, err)
+	}
+	if circuit.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", circuit.calls)
+	}
+	want := []retry{
+		{1, errUnavailable, time.Millisecond},
+		{2, errUnavailable, time.Millisecond * 2},
+	}
+	if len(retries) != len(want) || retries[0] != want[0] || retries[1] != want[1] {
+		t.Fatalf("expected retries %v, got %v", want, retries)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	errFirst := errors.New("first")
+	circuit := &failingCircuit{errs: []error{errFirst, errUnavailable, errUnavailable, errUnavailable}}
+	r := dist.Retry(circuit.call,
+		dist.WithMaxAttempts(3),
+		dist.WithBackoff(dist.NewConstantBackoff(0)),
+	)
+
+	_, err := r(context.Background())
+	var re *dist.RetryError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected a *RetryError, got %v", err)
+	}
+	if len(re.Errors) != 3 || circuit.calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d errors after %d calls", len(re.Errors), circuit.calls)
+	}
+	if !errors.Is(err, errFirst) || !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected every attempt error to be wrapped, got %v", err)
+	}
+}
+
+func TestRetryIf(t *testing.T) {
+	circuit := &failingCircuit{errs: []error{dist.Permanent(errUnavailable)}}
+	r := dist.Retry(circuit.call, dist.WithBackoff(dist.NewConstantBackoff(0)))
+
+	if _, err := r(context.Background()); !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the permanent error, got %v", err)
+	}
+	if circuit.calls != 1 {
+		t.Fatalf("expected a permanent error not to be retried, got %d calls", circuit.calls)
+	}
+
+	circuit = &failingCircuit{errs: []error{dist.Permanent(errUnavailable)}}
+	r = dist.Retry(circuit.call,
+		dist.WithBackoff(dist.NewConstantBackoff(0)),
+		dist.WithRetryIf(func(error) bool { return true }),
+	)
+	if _, err := r(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	circuit := &failingCircuit{errs: []error{errUnavailable, errUnavailable, errUnavailable}}
+	r := dist.Retry(circuit.call,
+		dist.WithClock(clock),
+		dist.WithMaxAttempts(10),
+		dist.WithMaxElapsed(time.Second*5),
+		dist.WithBackoff(dist.NewConstantBackoff(time.Second*3)),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r(context.Background())
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second * 3)
+	if err := <-done; err == nil {
+		t.Fatal("expected the retry to run out of time")
+	}
+	if circuit.calls != 2 {
+		t.Fatalf("expected 2 attempts within 5s, got %d", circuit.calls)
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	calls := 0
+	r := dist.Retry(func(ctx context.Context) (*string, error) {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		res := "ok"
+		return &res, nil
+	},
+		dist.WithAttemptTimeout(time.Millisecond*10),
+		dist.WithBackoff(dist.NewConstantBackoff(0)),
+	)
+
+	res, err := r(context.Background())
+	if err != nil || *res != "ok" {
+		t.Fatalf("expected the timed out attempt to be retried, got %v, %v", res, err)
+	}
+}
+
+func TestRetryAttemptTimeoutOnClock(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var calls atomic.Int64
+	r := dist.Retry(func(ctx context.Context) (*string, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		res := "ok"
+		return &res, nil
+	},
+		dist.WithClock(clock),
+		dist.WithAttemptTimeout(time.Millisecond*10),
+		dist.WithBackoff(dist.NewConstantBackoff(0)),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r(context.Background())
+		done <- err
+	}()
+
+	// the attempt does not time out while the clock stands still
+	select {
+	case err := <-done:
+		t.Fatalf("expected the attempt to wait for the clock, got %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+	clock.BlockUntil(1)
+	clock.Advance(time.Millisecond * 10)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the timed out attempt to be retried, got %v", err)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	circuit := &failingCircuit{errs: []error{errUnavailable}}
+	r := dist.Retry(circuit.call,
+		dist.WithClock(clock),
+		dist.WithBackoff(dist.NewConstantBackoff(time.Minute)),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := r(ctx)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	cancel()
+	err := <-done
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected both the attempt error and context.Canceled, got %v", err)
+	}
+	if circuit.calls != 1 {
+		t.Fatalf("expected no attempt after cancel, got %d calls", circuit.calls)
+	}
+}
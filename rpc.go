@@ -0,0 +1,116 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sync"
+)
+
+// serveRPC registers rcvr under name on a new RPC server and serves every
+// connection accepted on l. It returns the error that stops l from accepting,
+// for example once l is closed.
+func serveRPC(l net.Listener, name string, rcvr any) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(name, rcvr); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.ServeConn(conn)
+	}
+}
+
+// rpcClient is an RPC client that dials lazily and dials again after its
+// connection breaks, so that a server that was unreachable for a while is
+// picked up again once it is back.
+type rpcClient struct {
+	network string
+	addr    string
+
+	mu     sync.Mutex
+	client *rpc.Client
+}
+
+func newRPCClient(network string, addr string) *rpcClient {
+	return &rpcClient{
+		network: network,
+		addr:    addr,
+	}
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, args any, reply any) error {
+	client, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The reply is decoded into a value owned by the call, and copied to
+	// reply only once the call is done, so that a reply arriving after ctx
+	// ended is never written to a value the caller has moved on with. An
+	// abandoned call is left to complete on its own; the connection is
+	// shared by every other call in flight and stays open.
+	dst := reflect.ValueOf(reply)
+	owned := reflect.New(dst.Type().Elem())
+	call := client.Go(method, args, owned.Interface(), make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var serverErr rpc.ServerError
+	if call.Error != nil && !errors.As(call.Error, &serverErr) {
+		c.reset(client)
+	}
+	if call.Error == nil {
+		dst.Elem().Set(owned.Elem())
+	}
+	return call.Error
+}
+
+func (c *rpcClient) connect(ctx context.Context) (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return nil, err
+	}
+	c.client = rpc.NewClient(conn)
+	return c.client, nil
+}
+
+// reset drops a broken client unless it has already been replaced.
+func (c *rpcClient) reset(client *rpc.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == client {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+func (c *rpcClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
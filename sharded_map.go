@@ -50,6 +50,49 @@ func (sm *ShardedMap[V]) Delete(key string) {
 	delete(sm.shards[shard].m, key)
 }
 
+// GetOrSet returns the value of key, first setting it to the value returned
+// by create if the key is missing. create is called with the shard of key
+// locked, at most once per missing key.
+func (sm *ShardedMap[V]) GetOrSet(key string, create func() V) V {
+	if val, ok := sm.Get(key); ok {
+		return val
+	}
+
+	shard := sm.getShard(key)
+	sm.shards[shard].Lock()
+	defer sm.shards[shard].Unlock()
+	if val, ok := sm.shards[shard].m[key]; ok {
+		return val
+	}
+	val := create()
+	sm.shards[shard].m[key] = val
+	return val
+}
+
+// DeleteFunc deletes every key for which del returns true. del is called with
+// the shard of the key locked.
+func (sm *ShardedMap[V]) DeleteFunc(del func(key string, val V) bool) {
+	for _, shard := range sm.shards {
+		shard.Lock()
+		for key, val := range shard.m {
+			if del(key, val) {
+				delete(shard.m, key)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+func (sm *ShardedMap[V]) Len() int {
+	n := 0
+	for _, shard := range sm.shards {
+		shard.RLock()
+		n += len(shard.m)
+		shard.RUnlock()
+	}
+	return n
+}
+
 func (sm *ShardedMap[V]) Keys() []string {
 	keys := make([]string, 0, len(sm.shards))
 	mut := sync.Mutex{}
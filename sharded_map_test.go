@@ -73,3 +73,42 @@ func TestShardedMap(t *testing.T) {
 		t.Fatalf("expected [create read update delete list get], got %v", keys)
 	}
 }
+
+func TestShardedMapGetOrSet(t *testing.T) {
+	smap := dist.NewShardedMap[int](3)
+
+	created := 0
+	create := func() int {
+		created++
+		return created
+	}
+	if v := smap.GetOrSet("a", create); v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+	if v := smap.GetOrSet("a", create); v != 1 {
+		t.Fatalf("expected the existing value 1, got %d", v)
+	}
+	if created != 1 {
+		t.Fatalf("expected create to be called once, got %d", created)
+	}
+}
+
+func TestShardedMapDeleteFunc(t *testing.T) {
+	smap := dist.NewShardedMap[int](3)
+	for i, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		smap.Set(key, i)
+	}
+
+	smap.DeleteFunc(func(_ string, v int) bool {
+		return v%2 == 0
+	})
+	if smap.Len() != 3 {
+		t.Fatalf("expected 3 keys, got %d", smap.Len())
+	}
+	if _, ok := smap.Get("a"); ok {
+		t.Fatal("expected key a to be deleted")
+	}
+	if _, ok := smap.Get("b"); !ok {
+		t.Fatal("expected key b to be kept")
+	}
+}
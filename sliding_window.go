@@ -0,0 +1,195 @@
+package dist
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLog is a rate limiter letting at most limit units through in
+// any period of one window. It remembers when every unit it let through was
+// taken, so it is exact but holds up to limit timestamps.
+type SlidingWindowLog struct {
+	limit  int
+	window time.Duration
+	clock  Clock
+
+	mu sync.Mutex
+	// log is a ring buffer of the times units were taken, oldest first
+	// starting at head.
+	log  []time.Time
+	head int
+	size int
+}
+
+// NewSlidingWindowLog returns a limiter letting limit units through in any
+// period of one window.
+func NewSlidingWindowLog(limit uint, window time.Duration, opts ...ThrottleOption) *SlidingWindowLog {
+	cfg := newThrottleConfig(opts)
+
+	return &SlidingWindowLog{
+		limit:  int(limit),
+		window: window,
+		clock:  cfg.clock,
+		log:    make([]time.Time, limit),
+	}
+}
+
+// TakeN lets a call costing n units through if fewer than limit-n units were
+// taken in the last window, or returns a *ThrottleError telling when to try
+// again.
+func (l *SlidingWindowLog) TakeN(n uint) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.expire(now)
+
+	if int(n) > l.limit {
+		return l.rejection(maxDuration)
+	}
+	if over := l.size + int(n) - l.limit; over > 0 {
+		// The call fits once the over oldest units leave the window.
+		oldest := l.log[(l.head+over-1)%len(l.log)]
+		return l.rejection(oldest.Add(l.window).Sub(now))
+	}
+
+	for i := 0; i < int(n); i++ {
+		l.log[(l.head+l.size)%len(l.log)] = now
+		l.size++
+	}
+	return nil
+}
+
+// Limit returns the number of units let through per window.
+func (l *SlidingWindowLog) Limit() int {
+	return l.limit
+}
+
+// expire forgets the units taken a window or more before now.
+func (l *SlidingWindowLog) expire(now time.Time) {
+	start := now.Add(-l.window)
+	for l.size > 0 && !l.log[l.head].After(start) {
+		l.head = (l.head + 1) % len(l.log)
+		l.size--
+	}
+}
+
+func (l *SlidingWindowLog) rejection(retryAfter time.Duration) *ThrottleError {
+	return &ThrottleError{
+		RetryAfter: retryAfter,
+		Remaining:  l.limit - l.size,
+		Limit:      l.limit,
+	}
+}
+
+// SlidingWindowCounter is a rate limiter approximating SlidingWindowLog with
+// two counters: the units taken in the current fixed window and in the one
+// before it. The units of the previous window are assumed to be spread
+// evenly over it, and count in proportion to how much of it still overlaps
+// the sliding window.
+type SlidingWindowCounter struct {
+	limit  int64
+	window time.Duration
+	clock  Clock
+
+	mu    sync.Mutex
+	start time.Time
+	prev  int64
+	curr  int64
+}
+
+// NewSlidingWindowCounter returns a limiter letting about limit units
+// through in any period of one window.
+func NewSlidingWindowCounter(limit uint, window time.Duration, opts ...ThrottleOption) *SlidingWindowCounter {
+	cfg := newThrottleConfig(opts)
+	if window <= 0 {
+		window = 1
+	}
+
+	return &SlidingWindowCounter{
+		limit:  int64(limit),
+		window: window,
+		clock:  cfg.clock,
+		start:  cfg.clock.Now(),
+	}
+}
+
+// TakeN lets a call costing n units through if the estimated units taken in
+// the last window leave room for it, or returns a *ThrottleError telling
+// when to try again.
+func (c *SlidingWindowCounter) TakeN(n uint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	c.advance(now)
+
+	elapsed := now.Sub(c.start)
+	if c.estimate(elapsed)+int64(n) <= c.limit {
+		c.curr += int64(n)
+		return nil
+	}
+
+	retryAfter := maxDuration
+	if int64(n) <= c.limit {
+		retryAfter = c.retryAfter(elapsed, int64(n))
+	}
+	return &ThrottleError{
+		RetryAfter: retryAfter,
+		Remaining:  int(max(c.limit-c.estimate(elapsed), 0)),
+		Limit:      int(c.limit),
+	}
+}
+
+// Limit returns the number of units let through per window.
+func (c *SlidingWindowCounter) Limit() int {
+	return int(c.limit)
+}
+
+// advance moves to the fixed window holding now.
+func (c *SlidingWindowCounter) advance(now time.Time) {
+	windows := now.Sub(c.start) / c.window
+	if windows <= 0 {
+		return
+	}
+	c.start = c.start.Add(windows * c.window)
+	if windows == 1 {
+		c.prev = c.curr
+	} else {
+		c.prev = 0
+	}
+	c.curr = 0
+}
+
+// estimate returns the units taken in the sliding window ending elapsed into
+// the current fixed window, rounded up.
+func (c *SlidingWindowCounter) estimate(elapsed time.Duration) int64 {
+	overlap := float64(c.window-elapsed) / float64(c.window)
+	return c.curr + int64(math.Ceil(float64(c.prev)*overlap))
+}
+
+// retryAfter returns how long until n units fit, assuming nothing else is
+// taken meanwhile.
+func (c *SlidingWindowCounter) retryAfter(elapsed time.Duration, n int64) time.Duration {
+	// fits returns how far into a fixed window, whose previous window took
+	// prev units and which itself took curr, the sliding window leaves room
+	// for n units, or false if it never does within that window.
+	fits := func(prev int64, curr int64) (time.Duration, bool) {
+		room := c.limit - curr - n
+		if room < 0 {
+			return 0, false
+		}
+		if prev <= room {
+			return 0, true
+		}
+		share := 1 - float64(room)/float64(prev)
+		return time.Duration(math.Ceil(share * float64(c.window))), true
+	}
+
+	if at, ok := fits(c.prev, c.curr); ok && at > elapsed {
+		return at - elapsed
+	}
+	at, _ := fits(c.curr, 0)
+	return c.window - elapsed + at
+}
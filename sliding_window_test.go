@@ -0,0 +1,88 @@
+package dist_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestSlidingWindowLog(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	l := dist.NewSlidingWindowLog(3, time.Second, dist.WithClock(clock))
+
+	if err := l.TakeN(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(time.Millisecond * 400)
+	if err := l.TakeN(2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock.Advance(time.Millisecond * 400)
+	var te *dist.ThrottleError
+	if !errors.As(l.TakeN(1), &te) {
+		t.Fatal("expected the window to be full")
+	}
+	if te.RetryAfter != time.Millisecond*200 {
+		t.Fatalf("expected to retry after 200ms, got %v", te.RetryAfter)
+	}
+	if !errors.As(l.TakeN(2), &te) || te.RetryAfter != time.Millisecond*600 {
+		t.Fatalf("expected 2 units to fit after 600ms, got %v", te.RetryAfter)
+	}
+
+	clock.Advance(time.Millisecond * 200)
+	if err := l.TakeN(1); err != nil {
+		t.Fatalf("expected the first unit to have left the window, got %v", err)
+	}
+	if err := l.TakeN(1); err == nil {
+		t.Fatal("expected the window to be full again")
+	}
+	if !errors.As(l.TakeN(4), &te) || te.RetryAfter <= time.Hour {
+		t.Fatal("expected a cost above the limit never to be let through")
+	}
+}
+
+func TestSlidingWindowCounter(t *testing.T) {
+	start := time.Now()
+	clock := dist.NewFakeClock(start)
+	c := dist.NewSlidingWindowCounter(10, time.Second, dist.WithClock(clock))
+
+	if err := c.TakeN(10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var te *dist.ThrottleError
+	if !errors.As(c.TakeN(1), &te) {
+		t.Fatal("expected the window to be full")
+	}
+	if te.RetryAfter != time.Millisecond*1100 {
+		t.Fatalf("expected to retry after 1.1s, got %v", te.RetryAfter)
+	}
+
+	// Half of the previous window still overlaps the sliding window, so its
+	// 10 units count as 5.
+	clock.Set(start.Add(time.Millisecond * 1500))
+	if err := c.TakeN(5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.As(c.TakeN(1), &te) {
+		t.Fatal("expected the sliding window to be full")
+	}
+	if te.RetryAfter != time.Millisecond*100 {
+		t.Fatalf("expected to retry after 100ms, got %v", te.RetryAfter)
+	}
+	if te.Remaining != 0 || te.Limit != 10 {
+		t.Fatalf("expected 0 of 10 remaining, got %d of %d", te.Remaining, te.Limit)
+	}
+
+	clock.Advance(time.Millisecond * 100)
+	if err := c.TakeN(1); err != nil {
+		t.Fatalf("expected a unit to fit, got %v", err)
+	}
+
+	clock.Advance(time.Second * 2)
+	if err := c.TakeN(10); err != nil {
+		t.Fatalf("expected old windows to be forgotten, got %v", err)
+	}
+}
@@ -2,48 +2,111 @@ package dist
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 )
 
-type Effector[T any] func(ctx context.Context) (*T, error)
+// ErrThrottled is matched by every error returned when a throttle rejects a
+// call. Use errors.As with a *ThrottleError to find out when to try again.
+var ErrThrottled = errors.New("throttle: too many calls")
 
-func ThrottleWithRefill[T any](e Effector[T], maxTokens uint, refillTokens uint, refillDuration time.Duration) Effector[T] {
-	var currentTokens = maxTokens
-	var once sync.Once
+// ThrottleError is returned when a throttle rejects a call.
+type ThrottleError struct {
+	// RetryAfter estimates how long until the call would be let through. It
+	// is the largest possible duration if the call never would.
+	RetryAfter time.Duration
+	// Remaining is the number of tokens left when the call was rejected.
+	Remaining int
+	// Limit is the capacity of the throttle.
+	Limit int
+}
+
+func (e *ThrottleError) Error() string {
+	if e.RetryAfter == maxDuration {
+		return ErrThrottled.Error()
+	}
+	return fmt.Sprintf("%s, retry after %v", ErrThrottled, e.RetryAfter)
+}
+
+// Is reports whether target is ErrThrottled.
+func (e *ThrottleError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Effector is the name the throttles in this package give the function they
+// wrap. It is an alias of Circuit, so the two can be used interchangeably.
+type Effector[T any] = Circuit[T]
+
+type throttleConfig struct {
+	clock   Clock
+	wait    bool
+	idleTTL time.Duration
+	burst   uint
+
+	storeTimeout time.Duration
+	fallback     *KeyedLimiter
+}
+
+// ThrottleOption configures a throttle.
+type ThrottleOption interface {
+	applyThrottle(*throttleConfig)
+}
+
+type throttleOptionFunc func(*throttleConfig)
+
+func (f throttleOptionFunc) applyThrottle(c *throttleConfig) {
+	f(c)
+}
+
+// WithThrottleWait makes the throttle block a call until a token is available
+// instead of rejecting it. Waiting calls are served in the order they
+// arrived. A call fails right away if its context deadline would pass before
+// its token is available, and gives its token back if its context ends
+// while it waits.
+func WithThrottleWait() ThrottleOption {
+	return throttleOptionFunc(func(c *throttleConfig) {
+		c.wait = true
+	})
+}
+
+func newThrottleConfig(opts []ThrottleOption) throttleConfig {
+	cfg := throttleConfig{
+		clock: RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyThrottle(&cfg)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+	return cfg
+}
+
+// ThrottleWithRefill wraps an effector with a TokenBucket holding up to
+// maxTokens tokens and refilled with refillTokens tokens every
+// refillDuration. Every call takes a token; calls made while the bucket is
+// empty are rejected with a *ThrottleError without calling the effector, or
+// wait for their token with WithThrottleWait.
+func ThrottleWithRefill[T any](e Effector[T], maxTokens uint, refillTokens uint, refillDuration time.Duration, opts ...ThrottleOption) Effector[T] {
+	bucket := NewTokenBucket(maxTokens, refillTokens, refillDuration, opts...)
+	cfg := newThrottleConfig(opts)
 
 	return func(ctx context.Context) (*T, error) {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
-		once.Do(func() {
-			ticker := time.NewTicker(refillDuration)
-
-			go func() {
-				defer ticker.Stop()
-
-				for {
-					select {
-					case <-ticker.C:
-						t := currentTokens + refillTokens
-						if t > maxTokens {
-							t = maxTokens
-						}
-						currentTokens = t
-					case <-ctx.Done():
-						return
-					}
-				}
-			}()
-		})
-
-		if currentTokens <= 0 {
-			return nil, fmt.Errorf("throttle: too many calls")
+		if cfg.wait {
+			if err := bucket.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return e(ctx)
 		}
 
-		currentTokens--
+		if err := bucket.take(1); err != nil {
+			return nil, err
+		}
 
 		return e(ctx)
 	}
@@ -2,6 +2,7 @@ package dist_test
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -59,3 +60,86 @@ func TestThrottleWithRefill_Refill(t *testing.T) {
 	}
 	mockEffectorCalled = 0
 }
+
+func TestThrottleWithRefillOutlivesFirstCaller(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var calls int64
+	effector := dist.ThrottleWithRefill(func(ctx context.Context) (*string, error) {
+		calls++
+		return nil, nil
+	}, 1, 1, time.Second, dist.WithClock(clock))
+
+	// the bucket keeps refilling after the context of the first call ends
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _ = effector(ctx)
+	cancel()
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		if _, err := effector(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 4 {
+		t.Fatalf("expected 4 calls, got %d", calls)
+	}
+	if _, err := effector(context.Background()); err == nil {
+		t.Fatal("expected call to be throttled")
+	}
+}
+
+func TestThrottleWithRefillWait(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	var calls atomic.Int64
+	effector := dist.ThrottleWithRefill(func(ctx context.Context) (*string, error) {
+		calls.Add(1)
+		return nil, nil
+	}, 2, 1, time.Second, dist.WithClock(clock), dist.WithThrottleWait())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := effector(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// two calls go through right away, the other two wait for refills
+	clock.BlockUntil(2)
+	clock.Advance(time.Second * 2)
+	wg.Wait()
+	if calls.Load() != 4 {
+		t.Fatalf("expected 4 calls, got %d", calls.Load())
+	}
+}
+
+func TestThrottleWithRefillError(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	effector := dist.ThrottleWithRefill(mockEffector, 3, 1, time.Second, dist.WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		if _, err := effector(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	clock.Advance(time.Millisecond * 400)
+
+	_, err := effector(context.Background())
+	if !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled, got %v", err)
+	}
+	var te *dist.ThrottleError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected a *ThrottleError, got %T", err)
+	}
+	if te.RetryAfter != time.Millisecond*600 {
+		t.Errorf("expected to retry after 600ms, got %v", te.RetryAfter)
+	}
+	if te.Remaining != 0 || te.Limit != 3 {
+		t.Errorf("expected 0 of 3 tokens remaining, got %d of %d", te.Remaining, te.Limit)
+	}
+	atomic.StoreInt64(&mockEffectorCalled, 0)
+}
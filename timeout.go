@@ -0,0 +1,123 @@
+package dist
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned by a timeout that gives up on a call because it did
+// not complete in time. The context passed to the call ends with ErrTimeout
+// as its cause.
+var ErrTimeout = errors.New("timeout: call did not complete in time")
+
+// AbandonedFunc is called when a call that a timeout gave up on completes
+// after all, elapsed after it started, with the error it returned.
+type AbandonedFunc func(elapsed time.Duration, err error)
+
+type timeoutConfig struct {
+	clock       Clock
+	onAbandoned AbandonedFunc
+}
+
+// TimeoutOption configures a timeout.
+type TimeoutOption interface {
+	applyTimeout(*timeoutConfig)
+}
+
+type timeoutOptionFunc func(*timeoutConfig)
+
+func (f timeoutOptionFunc) applyTimeout(c *timeoutConfig) {
+	f(c)
+}
+
+// WithOnAbandoned registers a callback invoked when a call that timed out, or
+// whose caller gave up, completes after all. A call that keeps running long
+// after its context ended points at a dependency leaking work. The callback
+// runs on the goroutine that ran the call.
+func WithOnAbandoned(fn AbandonedFunc) TimeoutOption {
+	return timeoutOptionFunc(func(c *timeoutConfig) {
+		c.onAbandoned = fn
+	})
+}
+
+// Timeout wraps a circuit so that every call gets a context ending d after it
+// starts, and returns ErrTimeout once d has elapsed even if the circuit
+// ignores its context and never returns. The circuit runs on a goroutine of
+// its own; if the caller's context ends first, Timeout returns its error
+// right away as well. The context carries a deadline only on the real clock,
+// since Go would end it on the wall clock regardless of WithClock.
+func Timeout[T any](circuit Circuit[T], d time.Duration, opts ...TimeoutOption) Circuit[T] {
+	cfg := timeoutConfig{
+		clock: RealClock(),
+	}
+	for _, opt := range opts {
+		opt.applyTimeout(&cfg)
+	}
+	if cfg.clock == nil {
+		cfg.clock = RealClock()
+	}
+
+	type result struct {
+		res *T
+		err error
+	}
+
+	return func(ctx context.Context) (*T, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		start := cfg.clock.Now()
+		callCtx, cancel := withClockTimeout(ctx, cfg.clock, d, ErrTimeout)
+		defer cancel()
+
+		var mu sync.Mutex
+		var abandoned bool
+		done := make(chan result, 1)
+		go func() {
+			res, err := circuit(callCtx)
+
+			mu.Lock()
+			if !abandoned {
+				done <- result{res, err}
+				mu.Unlock()
+				return
+			}
+			mu.Unlock()
+			if cfg.onAbandoned != nil {
+				cfg.onAbandoned(cfg.clock.Now().Sub(start), err)
+			}
+		}()
+
+		// A circuit honouring its context may return its own error as soon
+		// as the call ends; that still makes the call time out.
+		timedOut := func(r result) (*T, error) {
+			if r.err != nil && ctx.Err() == nil && errors.Is(context.Cause(callCtx), ErrTimeout) {
+				return nil, ErrTimeout
+			}
+			return r.res, r.err
+		}
+
+		select {
+		case r := <-done:
+			return timedOut(r)
+		case <-callCtx.Done():
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		select {
+		case r := <-done:
+			return timedOut(r)
+		default:
+		}
+		abandoned = true
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrTimeout
+	}
+}
@@ -0,0 +1,129 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestTimeoutReturnsResult(t *testing.T) {
+	circuit := dist.Timeout(func(ctx context.Context) (*string, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("expected a deadline")
+		}
+		res := "ok"
+		return &res, nil
+	}, time.Minute)
+
+	res, err := circuit(context.Background())
+	if err != nil || *res != "ok" {
+		t.Fatalf("unexpected result %v, %v", res, err)
+	}
+}
+
+func TestTimeoutFires(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	release := make(chan struct{})
+	abandoned := make(chan time.Duration, 1)
+	var cause error
+
+	circuit := dist.Timeout(func(ctx context.Context) (*string, error) {
+		<-ctx.Done()
+		cause = context.Cause(ctx)
+		// Keep running, as a circuit ignoring its context would.
+		<-release
+		clock.Advance(time.Second)
+		return nil, nil
+	}, time.Second*5, dist.WithClock(clock), dist.WithOnAbandoned(func(elapsed time.Duration, err error) {
+		abandoned <- elapsed
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := circuit(context.Background())
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second * 5)
+	if err := <-done; !errors.Is(err, dist.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	close(release)
+	if elapsed := <-abandoned; elapsed != time.Second*6 {
+		t.Fatalf("expected the abandoned call to be reported after 6s, got %v", elapsed)
+	}
+	if !errors.Is(cause, dist.ErrTimeout) {
+		t.Fatalf("expected the call context to end with ErrTimeout, got %v", cause)
+	}
+}
+
+func TestTimeoutCallerCanceled(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	circuit := dist.Timeout(func(ctx context.Context) (*string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, time.Minute, dist.WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := circuit(ctx)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTimeoutContextAwareCircuit(t *testing.T) {
+	circuit := dist.Timeout(func(ctx context.Context) (*string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, time.Millisecond*10)
+
+	for i := 0; i < 20; i++ {
+		if _, err := circuit(context.Background()); !errors.Is(err, dist.ErrTimeout) {
+			t.Fatalf("expected ErrTimeout, got %v", err)
+		}
+	}
+}
+
+func TestTimeoutFollowsClock(t *testing.T) {
+	// a clock far in the past must not make the call time out at once
+	clock := dist.NewFakeClock(time.Unix(1000, 0))
+	release := make(chan struct{})
+	circuit := dist.Timeout(func(ctx context.Context) (*string, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+		}
+		res := "ok"
+		return &res, nil
+	}, time.Millisecond*20, dist.WithClock(clock))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := circuit(context.Background())
+		done <- err
+	}()
+
+	// the timeout does not fire while the clock stands still
+	select {
+	case err := <-done:
+		t.Fatalf("expected the call to wait for the clock, got %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the call to complete, got %v", err)
+	}
+}
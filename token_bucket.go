@@ -0,0 +1,272 @@
+package dist
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a rate limiter holding up to maxTokens tokens. Every call
+// takes a token, and refillTokens tokens are put back every refillDuration.
+// The bucket runs no goroutine: it works out the refills owed since it was
+// last used from the elapsed time whenever it is used, under a mutex, so it
+// lives exactly as long as the value itself and is safe for concurrent use.
+type TokenBucket struct {
+	maxTokens      int64
+	refillTokens   int64
+	refillDuration time.Duration
+	clock          Clock
+
+	mu     sync.Mutex
+	tokens int64
+	// last is the start of the refill interval the bucket is in.
+	last time.Time
+}
+
+// NewTokenBucket returns a full token bucket.
+func NewTokenBucket(maxTokens uint, refillTokens uint, refillDuration time.Duration, opts ...ThrottleOption) *TokenBucket {
+	cfg := newThrottleConfig(opts)
+
+	return &TokenBucket{
+		maxTokens:      int64(maxTokens),
+		refillTokens:   int64(refillTokens),
+		refillDuration: refillDuration,
+		clock:          cfg.clock,
+		tokens:         int64(maxTokens),
+		last:           cfg.clock.Now(),
+	}
+}
+
+// Allow takes a token if there is one and reports whether it did.
+func (b *TokenBucket) Allow() bool {
+	return b.AllowN(1)
+}
+
+// AllowN takes n tokens if there are that many and reports whether it did.
+func (b *TokenBucket) AllowN(n uint) bool {
+	return b.take(int64(n)) == nil
+}
+
+// TakeN takes n tokens if there are that many, or returns a *ThrottleError
+// telling when to try again.
+func (b *TokenBucket) TakeN(n uint) error {
+	return b.take(int64(n))
+}
+
+// take takes n tokens if there are that many, or returns a *ThrottleError
+// telling when to try again.
+func (b *TokenBucket) take(n int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if _, ok := b.reserve(now, n, 0); !ok {
+		return b.rejection(now, n)
+	}
+	return nil
+}
+
+// Wait blocks until it can take a token or ctx ends. Tokens are handed out in
+// the order Wait is called, and a token taken by Wait is never handed to a
+// later caller of Allow. Wait returns a *ThrottleError right away if the
+// deadline of ctx passes before the token would be available.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return b.WaitN(ctx, 1)
+}
+
+// WaitN is like Wait but takes n tokens. It returns an error right away if n
+// exceeds the capacity of the bucket.
+func (b *TokenBucket) WaitN(ctx context.Context, n uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	maxWait := time.Duration(-1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = deadline.Sub(b.clock.Now())
+	}
+
+	r, err := b.reserveN(n, maxWait)
+	if err != nil {
+		return err
+	}
+
+	delay := r.Delay()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := b.clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Reservation holds tokens taken from a TokenBucket ahead of time. The holder
+// may act once Delay has elapsed, or hand the tokens back with Cancel.
+type Reservation struct {
+	bucket *TokenBucket
+	ok     bool
+	tokens int64
+	at     time.Time
+
+	canceled bool
+}
+
+// Reserve takes n tokens from the bucket, running it into debt if there are
+// not that many, and returns a reservation telling when the caller may act.
+// The reservation is not OK, and takes nothing, if n exceeds the capacity of
+// the bucket or the bucket is never refilled.
+func (b *TokenBucket) Reserve(n uint) *Reservation {
+	r, _ := b.reserveN(n, -1)
+	return r
+}
+
+// reserveN reserves n tokens due within maxWait. If the reservation is not
+// OK, it also returns a *ThrottleError telling when to try again.
+func (b *TokenBucket) reserveN(n uint, maxWait time.Duration) (*Reservation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	at, ok := b.reserve(now, int64(n), maxWait)
+	r := &Reservation{
+		bucket: b,
+		ok:     ok,
+		tokens: int64(n),
+		at:     at,
+	}
+	if !ok {
+		return r, b.rejection(now, int64(n))
+	}
+	return r, nil
+}
+
+// OK reports whether the reservation holds its tokens.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the holder has to wait before acting. It returns
+// the largest possible duration if the reservation is not OK.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return maxDuration
+	}
+	d := r.at.Sub(r.bucket.clock.Now())
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Cancel hands the tokens of the reservation back to the bucket, unless the
+// reservation is not OK, has already been canceled, or its delay has already
+// elapsed, in which case the tokens are considered used.
+func (r *Reservation) Cancel() {
+	b := r.bucket
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if !r.ok || r.canceled || !now.Before(r.at) {
+		return
+	}
+	r.canceled = true
+
+	b.refill(now)
+	b.tokens += r.tokens
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+// reserve takes n tokens, running the bucket into debt if needed, and returns
+// when the tokens are due. It takes nothing and returns false if the bucket
+// can never hold n tokens, or if they are due more than maxWait from now;
+// a negative maxWait means any wait will do. It must be called with the
+// bucket locked.
+func (b *TokenBucket) reserve(now time.Time, n int64, maxWait time.Duration) (time.Time, bool) {
+	at, ok := b.due(now, n)
+	if !ok || (maxWait >= 0 && at.Sub(now) > maxWait) {
+		return time.Time{}, false
+	}
+
+	b.tokens -= n
+	return at, true
+}
+
+// due returns when n tokens will be available, after the tokens already
+// promised to earlier reservations, or false if that never happens. It must
+// be called with the bucket locked.
+func (b *TokenBucket) due(now time.Time, n int64) (time.Time, bool) {
+	if n > b.maxTokens {
+		return time.Time{}, false
+	}
+
+	b.refill(now)
+	if b.tokens >= n {
+		return now, true
+	}
+	if b.refillDuration <= 0 || b.refillTokens == 0 {
+		return time.Time{}, false
+	}
+	intervals := (n - b.tokens + b.refillTokens - 1) / b.refillTokens
+	return b.last.Add(b.refillDuration * time.Duration(intervals)), true
+}
+
+// rejection returns the error for a call that could not have n tokens. It
+// must be called with the bucket locked.
+func (b *TokenBucket) rejection(now time.Time, n int64) *ThrottleError {
+	retryAfter := maxDuration
+	if at, ok := b.due(now, n); ok {
+		retryAfter = at.Sub(now)
+	}
+
+	return &ThrottleError{
+		RetryAfter: retryAfter,
+		Remaining:  int(max(b.tokens, 0)),
+		Limit:      int(b.maxTokens),
+	}
+}
+
+// Tokens returns the number of tokens left in the bucket. It is negative
+// while callers are waiting for tokens that have not been refilled yet.
+func (b *TokenBucket) Tokens() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(b.clock.Now())
+	return int(b.tokens)
+}
+
+// Limit returns the capacity of the bucket.
+func (b *TokenBucket) Limit() int {
+	return int(b.maxTokens)
+}
+
+// refill adds the tokens owed for the refill intervals that ended since last.
+func (b *TokenBucket) refill(now time.Time) {
+	if b.refillDuration <= 0 || b.refillTokens == 0 {
+		return
+	}
+
+	intervals := int64(now.Sub(b.last) / b.refillDuration)
+	if intervals <= 0 {
+		return
+	}
+	b.last = b.last.Add(b.refillDuration * time.Duration(intervals))
+
+	if intervals > (b.maxTokens-b.tokens)/b.refillTokens {
+		b.tokens = b.maxTokens
+		return
+	}
+	b.tokens += intervals * b.refillTokens
+}
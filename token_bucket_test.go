@@ -0,0 +1,269 @@
+package dist_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dist "github.com/okulik/distributed-go"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(10, 3, time.Second, dist.WithClock(clock))
+
+	for i := 0; i < 10; i++ {
+		if !b.Allow() {
+			t.Fatalf("expected token %d to be allowed", i)
+		}
+	}
+	if b.Allow() {
+		t.Fatal("expected empty bucket")
+	}
+
+	clock.Advance(time.Millisecond * 999)
+	if b.Allow() {
+		t.Fatal("expected no refill before the interval ends")
+	}
+	clock.Advance(time.Millisecond)
+	if b.Tokens() != 3 {
+		t.Fatalf("expected 3 tokens, got %d", b.Tokens())
+	}
+
+	clock.Advance(time.Hour)
+	if b.Tokens() != b.Limit() {
+		t.Fatalf("expected a full bucket, got %d", b.Tokens())
+	}
+}
+
+func TestTokenBucketConcurrent(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(100, 10, time.Second, dist.WithClock(clock))
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if b.Allow() {
+					atomic.AddInt64(&allowed, 1)
+				}
+				if j%100 == 0 {
+					_ = b.Tokens()
+				}
+			}
+		}()
+	}
+	// refills happen while the callers compete for tokens
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Second)
+	}
+	wg.Wait()
+
+	// the refills may find the bucket full, but never hand out extra tokens
+	if allowed < 100 || allowed > 150 {
+		t.Fatalf("expected 100 to 150 tokens to be taken, got %d", allowed)
+	}
+}
+
+func TestTokenBucketWaitFIFO(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(1, 1, time.Second, dist.WithClock(clock))
+	if !b.Allow() {
+		t.Fatal("expected a token")
+	}
+
+	served := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			if err := b.Wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+			served <- i
+		}(i)
+		// let each waiter queue up before the next one arrives
+		clock.BlockUntil(i + 1)
+	}
+
+	if b.Allow() {
+		t.Fatal("expected Allow not to jump the queue")
+	}
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		if got := <-served; got != i {
+			t.Fatalf("expected waiter %d to be served, got %d", i, got)
+		}
+	}
+}
+
+func TestTokenBucketWaitDeadline(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(1, 1, time.Minute, dist.WithClock(clock))
+	_ = b.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err := b.Wait(ctx)
+	var te *dist.ThrottleError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected a throttle error, got %v", err)
+	}
+	if te.RetryAfter != time.Minute {
+		t.Fatalf("expected to retry after a minute, got %v", te.RetryAfter)
+	}
+	if time.Since(start) > time.Millisecond*500 {
+		t.Fatal("expected Wait to fail fast")
+	}
+	if b.Tokens() != 0 {
+		t.Fatalf("expected no token to be taken, got %d tokens", b.Tokens())
+	}
+}
+
+func TestTokenBucketWaitDeadlineOnClock(t *testing.T) {
+	// the bucket clock runs an hour ahead, so a deadline two hours away
+	// leaves only an hour on it
+	now := time.Now()
+	clock := dist.NewFakeClock(now.Add(time.Hour))
+	b := dist.NewTokenBucket(1, 1, time.Minute*90, dist.WithClock(clock))
+	_ = b.Allow()
+
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Hour*2))
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, dist.ErrThrottled) {
+		t.Fatalf("expected the deadline to be measured on the bucket clock, got %v", err)
+	}
+}
+
+func TestTokenBucketWaitCanceled(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(1, 1, time.Second, dist.WithClock(clock))
+	_ = b.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- b.Wait(ctx)
+	}()
+	clock.BlockUntil(1)
+	if b.Tokens() != -1 {
+		t.Fatalf("expected the waiter to hold a token, got %d tokens", b.Tokens())
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if b.Tokens() != 0 {
+		t.Fatalf("expected the token to be given back, got %d tokens", b.Tokens())
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(10, 2, time.Second, dist.WithClock(clock))
+
+	r := b.Reserve(10)
+	if !r.OK() || r.Delay() != 0 {
+		t.Fatalf("expected an immediate reservation, got ok=%v delay=%v", r.OK(), r.Delay())
+	}
+
+	r = b.Reserve(4)
+	if !r.OK() {
+		t.Fatal("expected the reservation to run the bucket into debt")
+	}
+	if r.Delay() != 2*time.Second {
+		t.Fatalf("expected a delay of 2s, got %v", r.Delay())
+	}
+	if b.Tokens() != -4 {
+		t.Fatalf("expected -4 tokens, got %d", b.Tokens())
+	}
+
+	r.Cancel()
+	if b.Tokens() != 0 {
+		t.Fatalf("expected the tokens back after cancel, got %d", b.Tokens())
+	}
+	r.Cancel()
+	if b.Tokens() != 0 {
+		t.Fatalf("expected a second cancel to do nothing, got %d", b.Tokens())
+	}
+
+	r = b.Reserve(2)
+	clock.Advance(time.Second)
+	if r.Delay() != 0 {
+		t.Fatalf("expected the delay to have elapsed, got %v", r.Delay())
+	}
+	r.Cancel()
+	if b.Tokens() != 0 {
+		t.Fatalf("expected a used reservation to keep its tokens, got %d", b.Tokens())
+	}
+
+	r = b.Reserve(11)
+	if r.OK() {
+		t.Fatal("expected a reservation above capacity to fail")
+	}
+	if r.Delay() <= time.Hour {
+		t.Fatalf("expected an unbounded delay, got %v", r.Delay())
+	}
+	if b.Tokens() != 0 {
+		t.Fatalf("expected a failed reservation to take nothing, got %d", b.Tokens())
+	}
+}
+
+func TestTokenBucketAllowN(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(10, 5, time.Second, dist.WithClock(clock))
+
+	if !b.AllowN(7) {
+		t.Fatal("expected 7 tokens to be allowed")
+	}
+	if b.AllowN(4) {
+		t.Fatal("expected 4 tokens to be rejected with 3 left")
+	}
+	if b.Tokens() != 3 {
+		t.Fatalf("expected a rejected call to take nothing, got %d", b.Tokens())
+	}
+	if !b.AllowN(0) {
+		t.Fatal("expected a zero cost to be allowed")
+	}
+
+	clock.Advance(time.Second)
+	if !b.AllowN(8) {
+		t.Fatal("expected 8 tokens to be allowed after a refill")
+	}
+}
+
+func TestTokenBucketWaitN(t *testing.T) {
+	clock := dist.NewFakeClock(time.Now())
+	b := dist.NewTokenBucket(4, 1, time.Second, dist.WithClock(clock))
+
+	if err := b.WaitN(context.Background(), 5); err == nil {
+		t.Fatal("expected a cost above capacity to fail")
+	}
+	if err := b.WaitN(context.Background(), 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.WaitN(context.Background(), 3)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("expected WaitN to wait for all 3 tokens")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package dist
+
+import "time"
+
+// windowCounts holds call outcomes aggregated over a bucket or a whole
+// rolling window.
+type windowCounts struct {
+	calls    uint32
+	failures uint32
+	slow     uint32
+}
+
+func (wc *windowCounts) add(o windowCounts) {
+	wc.calls += o.calls
+	wc.failures += o.failures
+	wc.slow += o.slow
+}
+
+func (wc *windowCounts) sub(o windowCounts) {
+	wc.calls -= o.calls
+	wc.failures -= o.failures
+	wc.slow -= o.slow
+}
+
+// rollingWindow is a ring buffer of buckets aggregating call outcomes. A
+// count-based window keeps the outcomes of the last len(buckets) calls, one
+// per bucket. A time-based window splits its span into len(buckets) buckets
+// of equal width and forgets buckets that fall out of the span.
+type rollingWindow struct {
+	buckets   []windowCounts
+	total     windowCounts
+	head      int
+	width     time.Duration
+	headStart time.Time
+}
+
+func newCountWindow(size uint) *rollingWindow {
+	if size == 0 {
+		size = 1
+	}
+	return &rollingWindow{
+		buckets: make([]windowCounts, size),
+	}
+}
+
+func newTimeWindow(span time.Duration, buckets uint) *rollingWindow {
+	if buckets == 0 {
+		buckets = 1
+	}
+	width := span / time.Duration(buckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &rollingWindow{
+		buckets: make([]windowCounts, buckets),
+		width:   width,
+	}
+}
+
+func (w *rollingWindow) timeBased() bool {
+	return w.width > 0
+}
+
+// record adds the outcome of a single call to the window.
+func (w *rollingWindow) record(now time.Time, failure bool, slow bool) {
+	o := windowCounts{calls: 1}
+	if failure {
+		o.failures = 1
+	}
+	if slow {
+		o.slow = 1
+	}
+
+	if w.timeBased() {
+		w.advance(now)
+	} else {
+		w.head = (w.head + 1) % len(w.buckets)
+		w.total.sub(w.buckets[w.head])
+		w.buckets[w.head] = windowCounts{}
+	}
+
+	w.buckets[w.head].add(o)
+	w.total.add(o)
+}
+
+// totals returns the outcomes aggregated over the whole window.
+func (w *rollingWindow) totals(now time.Time) windowCounts {
+	if w.timeBased() {
+		w.advance(now)
+	}
+	return w.total
+}
+
+func (w *rollingWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = windowCounts{}
+	}
+	w.total = windowCounts{}
+	w.head = 0
+	w.headStart = time.Time{}
+}
+
+// advance moves the head of a time-based window to the bucket covering now,
+// clearing the buckets it passes.
+func (w *rollingWindow) advance(now time.Time) {
+	if w.headStart.IsZero() {
+		w.headStart = now
+		return
+	}
+
+	steps := int(now.Sub(w.headStart) / w.width)
+	if steps <= 0 {
+		return
+	}
+	if steps >= len(w.buckets) {
+		w.reset()
+		w.headStart = now
+		return
+	}
+
+	for i := 0; i < steps; i++ {
+		w.head = (w.head + 1) % len(w.buckets)
+		w.total.sub(w.buckets[w.head])
+		w.buckets[w.head] = windowCounts{}
+	}
+	w.headStart = w.headStart.Add(w.width * time.Duration(steps))
+}